@@ -24,12 +24,32 @@ func (t Tags) String() string {
 	return strings.Join([]string(t), ", ")
 }
 
+// A heading found in a blog file.
+type Heading struct {
+	Level int    // 1 for <h1>, 2 for <h2>, ...
+	ID string    // Anchor ID of the heading.
+	Title string // Plain text of the heading.
+}
+
+// A link or image found in a blog file.
+type Link struct {
+	URL string // Destination of the link as it is rendered.
+	Line int   // Line of the link in the blog file.
+}
+
 // The Blog file's contents after parsing it
 type BlogFileContents struct {
 	Title string `yaml:"title"`
 	Desc string `yaml:"desc"`
 	Tags Tags `yaml:"tags"`
+	NoIndex bool `yaml:"noindex"`
 	Content template.HTML
+	Headings []Heading `yaml:"-" toml:"-"` // Headings in the order they appear.
+	Summary template.HTML `yaml:"-" toml:"-"` // Content before the <!--more--> separator. Empty if there is none.
+	Text string `yaml:"-" toml:"-"`          // Plain text of Summary, or of Content if there is no summary.
+	WordCount int `yaml:"-" toml:"-"`
+	ReadingTime int `yaml:"-" toml:"-"`      // Estimated reading time in minutes.
+	Links []Link `yaml:"-" toml:"-"`        // Links and images in the order they appear. Set when rendering.
 }
 
 // Data for a Given Blog File
@@ -38,6 +58,7 @@ type BlogMetadata struct {
 	Hash FileHash     `json:"hash"`    // current SHA1 sum of the file
 	Updated time.Time `json:"updated"` // Update date of the file (bumped if there is a hash mismatch)
 	Created time.Time `json:"created"` // Creation date of the file
+	Bundle bool       `json:"bundle,omitempty"` // Whether the file is the index.md of a page bundle directory. The hash covers every file in it.
 }
 
 // sort.Interface Implementation for BlogMetadata.
@@ -66,16 +87,102 @@ type BlogFile struct {
 	BlogMetadata
 }
 
+// Parameters for feed generation.
+type FeedParams struct {
+	RSS bool         `json:"rss"`          // Generate an RSS 2.0 feed (feed.xml)
+	Atom bool        `json:"atom"`         // Generate an Atom feed (atom.xml)
+	JSON bool        `json:"json"`         // Generate a JSON Feed (feed.json)
+	MaxItems int     `json:"max_items"`    // Maximum number of entries in a feed. 0 means all entries.
+	FullContent bool `json:"full_content"` // Include the full HTML content of each entry in the feed.
+}
+
+// Parameters for the table of contents of blog pages.
+type TOCParams struct {
+	MinLevel int      `json:"min_level"`    // Smallest heading level included in the TOC (1 for <h1>)
+	MaxLevel int      `json:"max_level"`    // Largest heading level included in the TOC
+}
+
+// Parameters for parsing markdown.
+type MarkdownParams struct {
+	HighlightStyle string `json:"highlight_style"`    // Chroma style used for code blocks
+	HighlightDarkStyle string `json:"highlight_dark_style"` // Style used for dark color schemes. Needs HighlightClasses.
+	HighlightClasses bool `json:"highlight_classes"`  // Use CSS classes from assets/syntax.css instead of inline styles
+	LineNumbers bool      `json:"line_numbers"`       // Show line numbers in code blocks
+	Unsafe bool           `json:"unsafe"`             // Keep raw HTML in the output
+	Footnotes bool        `json:"footnotes"`
+	DefinitionLists bool  `json:"definition_lists"`
+	Typographer bool      `json:"typographer"`        // Replace quotes, dashes and ellipses with typographic ones
+	Emoji bool            `json:"emoji"`              // Replace :emoji: shortcodes
+	Math bool             `json:"math"`               // Render $...$ and $$...$$ TeX math as MathML
+}
+
+// Parameters for images used in blog files.
+type ImageParams struct {
+	Widths []int `json:"widths"` // Widths of the resized copies made of PNG and JPEG images. Empty disables resizing.
+	Sizes string `json:"sizes"`  // sizes attribute of images with resized copies
+	Lazy bool    `json:"lazy"`   // Add loading="lazy" to images
+	StripMetadata bool `json:"strip_metadata"` // Remove EXIF and XMP metadata, including locations, from copied JPEG and PNG images
+}
+
 // Parameters for a blog project unmarshalled from a config file.
 type ConfigFileParams struct {
 	Title string                        `json:"title"`                // Title of the blog
 	Desc string                         `json:"description"`          // Short description of the blog. Goes in the <meta> tags.
 	Tags Tags                           `json:"tags"`                 // Tags for the blog. Goes in the <meta> tags.
+	BaseURL string                      `json:"base_url"`             // Absolute URL the blog is hosted at. Required for feeds.
 	BlogURLPathPrefix string            `json:"blog_url_path_prefix"` // NOT IMPLEMENTED This prefix will be added to all in-site URLs that are generated.
 	RenderPath string                   `json:"renderpath"`           // Path to where the rendered files should be put.
 	TemplatePath string                 `json:"templatepath"`         // Path to template.
 	UseFileTimestampAsCreationDate bool `json:"use_file_timestamp_as_creation_date"` // Use File Timestamp As Creation date.
 	MetadataType MetadataType           `json:"metadata_type"`        // Type of the blog file metadata (TOML/YAML)
+	Feed FeedParams                     `json:"feed"`                 // Feed generation parameters.
+	Sitemap bool                        `json:"sitemap"`              // Generate sitemap.xml and robots.txt. Requires BaseURL.
+	PageSize int                        `json:"page_size"`            // Number of entries per listing page. 0 disables pagination.
+	RelatedPosts int                    `json:"related_posts"`        // Maximum number of related posts shown on a post.
+	TOC TOCParams                       `json:"toc"`                  // Table of contents parameters.
+	ExcerptWords int                    `json:"excerpt_words"`        // Number of words in generated excerpts.
+	Markdown MarkdownParams             `json:"markdown"`             // Markdown parser parameters.
+	LenientLinks bool                   `json:"lenient_links"`        // Warn about links to missing or untracked files instead of failing.
+	Graph bool                          `json:"graph"`                // Generate graph.json with the links between posts.
+	Images ImageParams                  `json:"images"`               // Image processing parameters.
+	Minify bool                         `json:"minify"`               // Minify rendered pages and template CSS/JS assets.
 	Files []BlogMetadata                `json:"files"`                // List of blog markdown files.
 }
 
+// Widths of the resized copies of images set in the config of new projects.
+// Existing projects only resize images once widths are set.
+var DefaultImageWidths = []int{ 480, 960, 1440 }
+
+// Config file parameters with their default values. Values missing from an
+// existing config file keep these defaults when it is loaded.
+func DefaultConfigFileParams() ConfigFileParams {
+	return ConfigFileParams{
+		Feed: FeedParams{
+			RSS: true,
+			Atom: true,
+			JSON: true,
+			MaxItems: 20,
+			FullContent: false,
+		},
+		Sitemap: true,
+		Graph: true,
+		RelatedPosts: 5,
+		TOC: TOCParams{
+			MinLevel: 2,
+			MaxLevel: 4,
+		},
+		ExcerptWords: 50,
+		Markdown: MarkdownParams{
+			HighlightStyle: "tango",
+			LineNumbers: true,
+			Unsafe: true,
+			Math: false,
+		},
+		Images: ImageParams{
+			Sizes: "(max-width: 800px) 100vw, 800px",
+			Lazy: true,
+			StripMetadata: true,
+		},
+	}
+}
+
@@ -0,0 +1,90 @@
+package blogtemplate
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aghorui/burlough/util"
+)
+
+// Directory of the template that assets are copied from, and the directory of
+// the rendered blog they are copied to.
+const AssetDirectory = "assets"
+
+// Number of hex digits of the content hash put in the names of fingerprinted
+// assets.
+const assetHashLength = 6
+
+// A fingerprinted copy of an asset.
+type Asset struct {
+	Path string      // Path of the copy relative to the root of the blog, such as assets/template_main.3f2a1c.css.
+	Integrity string // Subresource Integrity value of the asset, such as sha384-...
+}
+
+// Fingerprinted copies of the assets of a template, by their paths in the
+// asset directory. It is filled in when the assets are copied.
+type AssetManifest map[string]Asset
+
+// Returns the name of an asset with the hash of its contents before its
+// extension.
+func fingerprintedName(name string, data []byte) string {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])[:assetHashLength]
+	ext := path.Ext(name)
+
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// Writes a fingerprinted copy of an asset next to it and records it. name is
+// the path of the asset in the asset directory, and data its contents as
+// copied.
+func (m AssetManifest) add(dest string, name string, data []byte) error {
+	if m == nil {
+		return nil
+	}
+
+	copyName := fingerprintedName(name, data)
+	sum := sha512.Sum384(data)
+
+	err := os.WriteFile(filepath.Join(dest, filepath.FromSlash(copyName)), data, 0644)
+	if err != nil {
+		return util.Error(err)
+	}
+
+	m[name] = Asset{
+		Path: path.Join(AssetDirectory, copyName),
+		Integrity: "sha384-" + base64.StdEncoding.EncodeToString(sum[:]),
+	}
+
+	return nil
+}
+
+func (m AssetManifest) get(name string) (Asset, error) {
+	asset, ok := m[path.Clean(strings.TrimPrefix(name, "/"))]
+	if !ok {
+		return asset, fmt.Errorf("asset '%v' does not exist in the template", name)
+	}
+
+	return asset, nil
+}
+
+// Returns the path of the fingerprinted copy of an asset relative to the root
+// of the blog. Used as the asset template function.
+func (m AssetManifest) URL(name string) (string, error) {
+	asset, err := m.get(name)
+	return asset.Path, err
+}
+
+// Returns the Subresource Integrity value of an asset. Used as the integrity
+// template function.
+func (m AssetManifest) Integrity(name string) (string, error) {
+	asset, err := m.get(name)
+	return asset.Integrity, err
+}
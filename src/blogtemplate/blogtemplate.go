@@ -1,13 +1,22 @@
 package blogtemplate
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"html/template"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/aghorui/burlough/blog"
 	"github.com/aghorui/burlough/constants"
+	"github.com/aghorui/burlough/minify"
+	"github.com/aghorui/burlough/parse"
 	"github.com/aghorui/burlough/static"
 	"github.com/aghorui/burlough/util"
 	"github.com/otiai10/copy"
@@ -19,47 +28,124 @@ type BlogTemplate struct {
 	FrontPage *template.Template // Front Page Template
 	IndexPage *template.Template // Index Page Template
 	BlogPage *template.Template  // Blog Page Template
+	TagPage *template.Template   // Tag Page Template. Falls back to IndexPage.
+	ArchivePage *template.Template // Archive Page Template. Falls back to IndexPage.
+	Shortcodes parse.Shortcodes  // Built-in shortcodes and the ones in ShortcodeDirectory
+	Assets AssetManifest         // Fingerprinted copies of the assets, once they are copied.
 }
 
 const IndexPageTemplateFileName = "blog_list.html"
 const FrontPageTemplateFileName = "front_page.html"
 const BlogPageTemplateFileName  = "blog_page.html"
+const TagPageTemplateFileName   = "tag_page.html"
+const ArchivePageTemplateFileName = "archive.html"
 
-// Copies asset files of the template to the desired folder.
-func (b BlogTemplate) CopyAssetsToFolder(dest string) error {
+// Directory of the template that shortcode templates are loaded from.
+const ShortcodeDirectory = "shortcodes"
 
-	if b.TemplateFS == nil {
-		// Nothing to copy.
-		return nil
-	}
+// Directories of the template whose templates are shared by every page
+// template, for base layouts and partials such as headers and footers.
+const LayoutDirectory = "layouts"
+const PartialDirectory = "partials"
+
+// Directory that tag pages are rendered into.
+const TagDirectory = "tags"
 
-	finalDest := filepath.Join(dest, "assets")
+// Copies asset files of the template to the desired folder. The stylesheet
+// for class based highlighting is generated there as well if enabled. With
+// minifyAssets, CSS and JS files are minified, and the sizes of the minified
+// files are returned. A fingerprinted copy of every asset is written next to
+// it and recorded in the asset manifest of the template.
+func (b BlogTemplate) CopyAssetsToFolder(dest string, markdown blog.MarkdownParams, minifyAssets bool) (minify.Stats, error) {
+	var stats minify.Stats
+
+	finalDest := filepath.Join(dest, AssetDirectory)
 
 	err := os.MkdirAll(finalDest, 0755)
 	if err != nil {
-		return util.Error(err)
+		return stats, util.Error(err)
+	}
+
+	if markdown.HighlightClasses {
+		css, err := parse.SyntaxCSS(markdown)
+		if err != nil {
+			return stats, util.Error(err)
+		}
+
+		if minifyAssets {
+			minified := minify.CSS(css)
+			stats.Add(len(css), len(minified))
+			css = minified
+		}
+
+		err = os.WriteFile(filepath.Join(finalDest, parse.SyntaxStylesheetFileName), css, 0644)
+		if err != nil {
+			return stats, util.Error(err)
+		}
+
+		err = b.Assets.add(finalDest, parse.SyntaxStylesheetFileName, css)
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	if b.TemplateFS == nil {
+		// Nothing to copy.
+		return stats, nil
 	}
 
 	// This is a weird thing. I have to explicitly set the permissions of the
 	// embed.FS files to get the actually correct permissions ORed with the
 	// supposed umask. 0644 seems to get the job done.
-	err = copy.Copy("assets", finalDest, copy.Options{
+	err = copy.Copy(AssetDirectory, finalDest, copy.Options{
 		FS: *b.TemplateFS,
 		PermissionControl: copy.AddPermission(0644),
 	})
 
 	if err != nil {
-		return util.Error(err)
+		return stats, util.Error(err)
 	}
 
-	return nil
+	// The copies are minified in place, and fingerprinted once they are final.
+	err = fs.WalkDir(*b.TemplateFS, AssetDirectory, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		data, err := fs.ReadFile(*b.TemplateFS, p)
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimPrefix(p, AssetDirectory + "/")
+
+		if minifyAssets {
+			if minified, ok := minify.File(p, data); ok {
+				stats.Add(len(data), len(minified))
+				data = minified
+
+				err = os.WriteFile(filepath.Join(finalDest, filepath.FromSlash(name)), data, 0644)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return b.Assets.add(finalDest, name, data)
+	})
+
+	if err != nil {
+		return stats, fmt.Errorf("Error encountered while processing template assets: %w", err)
+	}
+
+	return stats, nil
 }
 
 func GetBlogFirst(entries []BlogTemplateEntry, numEntries int) []BlogTemplateEntry {
 	if numEntries >= len(entries) {
 		return entries
 	} else {
-		return entries[0:numEntries - 1]
+		return entries[:numEntries]
 	}
 }
 
@@ -67,28 +153,61 @@ var defaultFuncMap template.FuncMap = template.FuncMap{
 	"getBlogFirst": GetBlogFirst,
 };
 
+// Returns the template functions, including the ones looking up assets in
+// the manifest.
+func funcMap(assets AssetManifest) template.FuncMap {
+	funcs := template.FuncMap{
+		"asset": assets.URL,
+		"integrity": assets.Integrity,
+	}
+
+	for name, f := range defaultFuncMap {
+		funcs[name] = f
+	}
+
+	return funcs
+}
+
 // Loads a template into a struct
 func LoadTemplate(folder fs.FS) (BlogTemplate, error) {
 	var t BlogTemplate
 	var err error
 
 	t.TemplateFS = &folder
+	t.Assets = make(AssetManifest)
+	funcs := funcMap(t.Assets)
 
+	shared, err := sharedTemplateFiles(folder)
 	if err != nil {
 		return t, util.Error(err)
 	}
 
-	t.FrontPage, err = template.New(FrontPageTemplateFileName).Funcs(defaultFuncMap).ParseFS(folder, FrontPageTemplateFileName)
+	t.FrontPage, err = loadPageTemplate(folder, FrontPageTemplateFileName, shared, funcs)
 	if err != nil {
 		return t, util.Error(err)
 	}
 
-	t.BlogPage, err = template.New(BlogPageTemplateFileName).Funcs(defaultFuncMap).ParseFS(folder, BlogPageTemplateFileName)
+	t.BlogPage, err = loadPageTemplate(folder, BlogPageTemplateFileName, shared, funcs)
 	if err != nil {
 		return t, util.Error(err)
 	}
 
-	t.IndexPage, err = template.New(IndexPageTemplateFileName).Funcs(defaultFuncMap).ParseFS(folder, IndexPageTemplateFileName)
+	t.IndexPage, err = loadPageTemplate(folder, IndexPageTemplateFileName, shared, funcs)
+	if err != nil {
+		return t, util.Error(err)
+	}
+
+	t.TagPage, err = loadOptionalTemplate(folder, TagPageTemplateFileName, t.IndexPage, shared, funcs)
+	if err != nil {
+		return t, util.Error(err)
+	}
+
+	t.ArchivePage, err = loadOptionalTemplate(folder, ArchivePageTemplateFileName, t.IndexPage, shared, funcs)
+	if err != nil {
+		return t, util.Error(err)
+	}
+
+	t.Shortcodes, err = loadShortcodes(folder)
 	if err != nil {
 		return t, util.Error(err)
 	}
@@ -96,6 +215,84 @@ func LoadTemplate(folder fs.FS) (BlogTemplate, error) {
 	return t, nil
 }
 
+// Loads the built-in shortcodes, overridden by the ones in the shortcode
+// directory of the template.
+func loadShortcodes(folder fs.FS) (parse.Shortcodes, error) {
+	shortcodes := parse.BuiltinShortcodes()
+
+	custom, err := parse.LoadShortcodes(folder, ShortcodeDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, t := range custom {
+		shortcodes[name] = t
+	}
+
+	return shortcodes, nil
+}
+
+// Loads a template that does not have to be present in a template folder.
+// If it is missing, fallback is returned instead.
+func loadOptionalTemplate(folder fs.FS, name string, fallback *template.Template, shared []string, funcs template.FuncMap) (*template.Template, error) {
+	_, err := fs.Stat(folder, name)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return fallback, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return loadPageTemplate(folder, name, shared, funcs)
+}
+
+// Returns the paths of the templates in the layout and partial directories,
+// layouts first. The directories are optional.
+func sharedTemplateFiles(folder fs.FS) ([]string, error) {
+	files := make([]string, 0)
+
+	for _, dir := range []string{ LayoutDirectory, PartialDirectory } {
+		matches, err := fs.Glob(folder, path.Join(dir, "*.html"))
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, matches...)
+	}
+
+	return files, nil
+}
+
+// Loads a page template along with the shared templates. The page is parsed
+// last, so its {{define}}s override the {{block}}s of the layouts. Every page
+// gets its own copy of the shared templates, so pages can define the same
+// blocks differently.
+func loadPageTemplate(folder fs.FS, name string, shared []string, funcs template.FuncMap) (*template.Template, error) {
+	t := template.New(name).Funcs(funcs)
+	files := append(append([]string{}, shared...), name)
+
+	for _, file := range files {
+		data, err := fs.ReadFile(folder, file)
+		if err != nil {
+			return nil, err
+		}
+
+		// Shared templates are named by their paths, as in
+		// {{template "partials/header.html" .}}.
+		tmpl := t
+		if file != name {
+			tmpl = t.New(file)
+		}
+
+		_, err = tmpl.Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("Error encountered while parsing %v: %w", file, err)
+		}
+	}
+
+	return t, nil
+}
+
 // Gets the directory listing of default_export_template
 func GetDefaultExportTemplateFiles() []fs.DirEntry {
 	files, err := static.DefaultExportTemplate.ReadDir("default_export_template")
@@ -121,14 +318,14 @@ func GetDefaultExportTemplateFS() fs.FS {
 }
 
 var DefaultBlogTemplate BlogTemplate = func() BlogTemplate {
-	dir := GetDefaultExportTemplateFS()
+	t, err := LoadTemplate(GetDefaultExportTemplateFS())
 
-	return BlogTemplate{
-		TemplateFS: &dir,
-		FrontPage:  template.Must(template.New("front_page.html").Funcs(defaultFuncMap).ParseFS(GetDefaultExportTemplateFS(), "front_page.html")),
-		BlogPage:   template.Must(template.New("blog_page.html").Funcs(defaultFuncMap).ParseFS(GetDefaultExportTemplateFS(), "blog_page.html")),
-		IndexPage:  template.Must(template.New("blog_list.html").Funcs(defaultFuncMap).ParseFS(GetDefaultExportTemplateFS(), "blog_list.html")),
+	if err != nil {
+		util.LogErr(err)
+		panic(err)
 	}
+
+	return t
 }()
 
 func DumpDefaultExportTemplate(dest string) error {
@@ -161,28 +358,221 @@ type BlogTemplateEntry struct {
 	GlobalDesc string
 	Tags blog.Tags
 	GlobalTags blog.Tags
+	TagLinks []Tag
 	Created string
 	Updated string
+	CreatedTime time.Time
+	UpdatedTime time.Time
 	URL string
+	NoIndex bool
+	Robots string // Value for the robots <meta> tag. Empty if there is none.
 	Content template.HTML
+	TOC TOC       // Table of contents of the entry.
+	Summary template.HTML // Content before <!--more-->, or the excerpt as a paragraph.
+	Excerpt string        // Plain text summary of the entry.
+	Truncated bool        // Whether Summary leaves out part of the content.
+	WordCount int
+	ReadingTime int       // Estimated reading time in minutes.
 }
 
 func PrepareBlogTemplateEntry(b blog.BlogFile, finalPath string, globalDesc string, globalTags blog.Tags) BlogTemplateEntry {
 	var finalUpdated string = ""
+	var robots string = ""
 
 	if !b.Updated.IsZero() {
 		finalUpdated = util.GetStandardTimestampString(b.Updated)
 	}
 
+	if b.NoIndex {
+		robots = "noindex"
+	}
+
 	return BlogTemplateEntry{
 		Title: b.Title,
 		Desc: b.Desc,
 		GlobalDesc: globalDesc,
 		Tags: b.Tags,
 		GlobalTags: globalTags,
+		TagLinks: NewTags(b.Tags),
 		Created: util.GetStandardTimestampString(b.Created),
 		Updated: finalUpdated,
+		CreatedTime: b.Created,
+		UpdatedTime: b.Updated,
 		URL: filepath.Join("./", finalPath),
+		NoIndex: b.NoIndex,
+		Robots: robots,
 		Content: b.Content,
+		WordCount: b.WordCount,
+		ReadingTime: b.ReadingTime,
+	}
+}
+
+// Sets the summary and the excerpt of an entry. Without a <!--more-->
+// separator, the excerpt is made of the first excerptWords words.
+func SetSummary(e *BlogTemplateEntry, b blog.BlogFileContents, excerptWords int) {
+	if b.Summary != "" {
+		e.Summary = b.Summary
+		e.Excerpt = b.Text
+		e.Truncated = true
+		return
+	}
+
+	e.Excerpt, e.Truncated = util.TruncateWords(b.Text, excerptWords)
+
+	if e.Excerpt != "" {
+		e.Summary = template.HTML("<p>" + template.HTMLEscapeString(e.Excerpt) + "</p>")
+	}
+}
+
+// A tag that templates can link to.
+type Tag struct {
+	Name string
+	Slug string
+	URL string   // Path of the tag page, relative to the root of the blog.
+	Count int    // Number of entries with this tag. Only set in tag listings.
+}
+
+// Number of hex digits of the hash of the name added to tag slugs that lose
+// characters.
+const tagHashLength = 6
+
+// Returns the slug of a tag. Names that lose characters to sanitizing, such as
+// "C++" and "C#", get a hash of the name added so that they do not share a
+// slug with other tags, and so do tags named "index", as tags/index.html is
+// the tag overview page.
+func tagSlug(name string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(name), "-"))
+	slug := util.Slugify(name)
+
+	if slug == normalized && slug != "index" {
+		return slug
+	}
+
+	sum := sha256.Sum256([]byte(normalized))
+	hash := hex.EncodeToString(sum[:])[:tagHashLength]
+
+	if slug == "" {
+		return hash
+	}
+
+	return slug + "-" + hash
+}
+
+// Creates a tag from its name.
+func NewTag(name string) Tag {
+	slug := tagSlug(name)
+
+	return Tag{
+		Name: name,
+		Slug: slug,
+		URL: path.Join(TagDirectory, slug + ".html"),
+	}
+}
+
+// Creates tags from a list of tag names.
+func NewTags(t blog.Tags) []Tag {
+	tags := make([]Tag, 0, len(t))
+
+	for _, name := range t {
+		tags = append(tags, NewTag(name))
+	}
+
+	return tags
+}
+
+// An entry in a table of contents.
+type TOCItem struct {
+	Level int
+	ID string
+	Title string
+	URL string         // Link to the heading within the page ("#id").
+	Children []TOCItem // Headings nested under this one.
+}
+
+// Table of contents of an entry.
+type TOC struct {
+	Items []TOCItem    // Top level headings, with lower level headings nested in them.
+	HTML template.HTML // The table of contents as nested lists.
+}
+
+// Builds a table of contents from headings between minLevel and maxLevel
+// (inclusive). Headings without an ID cannot be linked to and are left out.
+func BuildTOC(headings []blog.Heading, minLevel int, maxLevel int) TOC {
+	type frame struct {
+		level int
+		items []TOCItem
 	}
-}
\ No newline at end of file
+
+	// stack[0] holds the top level items. Every other frame holds the children
+	// of the last item of the frame below it, and the level they start at.
+	stack := []frame{{ level: 0 }}
+
+	closeFrame := func() {
+		top := stack[len(stack) - 1]
+		stack = stack[:len(stack) - 1]
+		parent := &stack[len(stack) - 1]
+		parent.items[len(parent.items) - 1].Children = top.items
+	}
+
+	for _, h := range headings {
+		if h.Level < minLevel || h.Level > maxLevel || h.ID == "" {
+			continue
+		}
+
+		for len(stack) > 1 && stack[len(stack) - 1].level > h.Level {
+			closeFrame()
+		}
+
+		top := &stack[len(stack) - 1]
+
+		// Nest the heading under the previous one if it is of a higher level.
+		if n := len(top.items); n > 0 && top.items[n - 1].Level < h.Level {
+			stack = append(stack, frame{
+				level: h.Level,
+				items: top.items[n - 1].Children,
+			})
+			top = &stack[len(stack) - 1]
+		}
+
+		top.items = append(top.items, TOCItem{
+			Level: h.Level,
+			ID: h.ID,
+			Title: h.Title,
+			URL: "#" + h.ID,
+		})
+	}
+
+	for len(stack) > 1 {
+		closeFrame()
+	}
+
+	return TOC{
+		Items: stack[0].items,
+		HTML: tocHTML(stack[0].items),
+	}
+}
+
+// Renders TOC items as nested unordered lists.
+func tocHTML(items []TOCItem) template.HTML {
+	if len(items) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+
+	b.WriteString("<ul>")
+
+	for _, item := range items {
+		b.WriteString(`<li><a href="`)
+		b.WriteString(template.HTMLEscapeString(item.URL))
+		b.WriteString(`">`)
+		b.WriteString(template.HTMLEscapeString(item.Title))
+		b.WriteString("</a>")
+		b.WriteString(string(tocHTML(item.Children)))
+		b.WriteString("</li>")
+	}
+
+	b.WriteString("</ul>")
+
+	return template.HTML(b.String())
+}
@@ -1,10 +1,16 @@
 package blogtemplate
 
 import (
+	"crypto/sha512"
+	"encoding/base64"
+	"html/template"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 
+	"github.com/aghorui/burlough/blog"
 	"github.com/aghorui/burlough/constants"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -30,5 +36,116 @@ func TestTemplate(t *testing.T) {
 	tmpl, err := LoadTemplate(os.DirFS(templatePath))
 	assert.NoError(t, err, "there shouldn't be any error while loading the default template")
 
-	assert.NoError(t, tmpl.CopyAssetsToFolder(filepath.Join(dir, "assets")), "there shouldn't be any error while copying template assets to a folder")
-}
\ No newline at end of file
+	_, err = tmpl.CopyAssetsToFolder(filepath.Join(dir, "assets"), blog.MarkdownParams{ HighlightClasses: true }, false)
+	assert.NoError(t, err, "there shouldn't be any error while copying template assets to a folder")
+	assert.FileExists(t, filepath.Join(dir, "assets", "assets", "syntax.css"), "the syntax stylesheet should be generated")
+
+	stats, err := tmpl.CopyAssetsToFolder(filepath.Join(dir, "minified"), blog.MarkdownParams{ HighlightClasses: true }, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Files, "the stylesheets should be minified")
+	assert.Less(t, stats.After, stats.Before)
+
+	url, err := tmpl.Assets.URL("template_main.css")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^assets/template_main\.[0-9a-f]{6}\.css$`, url, "assets should be fingerprinted")
+	assert.FileExists(t, filepath.Join(dir, "minified", filepath.FromSlash(url)), "fingerprinted copies should be written next to the assets")
+
+	data, err := os.ReadFile(filepath.Join(dir, "minified", filepath.FromSlash(url)))
+	assert.NoError(t, err)
+	sum := sha512.Sum384(data)
+	integrity, err := tmpl.Assets.Integrity("template_main.css")
+	assert.NoError(t, err)
+	assert.Equal(t, "sha384-" + base64.StdEncoding.EncodeToString(sum[:]), integrity, "the integrity should match the minified copy")
+
+	_, err = tmpl.Assets.URL("missing.css")
+	assert.EqualError(t, err, "asset 'missing.css' does not exist in the template")
+}
+
+func TestOptionalTemplateFallback(t *testing.T) {
+	dir := t.TempDir()
+
+	templatePath := filepath.Join(dir, constants.AppName + "_default_export_template")
+
+	require.NoError(t, DumpDefaultExportTemplate(dir))
+	require.NoError(t, os.Remove(filepath.Join(templatePath, TagPageTemplateFileName)))
+
+	tmpl, err := LoadTemplate(os.DirFS(templatePath))
+	assert.NoError(t, err, "a missing tag page template should not be an error")
+	assert.Same(t, tmpl.IndexPage, tmpl.TagPage, "the tag page should fall back to the index page")
+}
+
+func TestGetBlogFirst(t *testing.T) {
+	entries := make([]BlogTemplateEntry, 7)
+
+	assert.Len(t, GetBlogFirst(entries, 5), 5, "the first numEntries entries should be returned")
+	assert.Len(t, GetBlogFirst(entries, 10), 7, "every entry should be returned if there are fewer")
+}
+
+func TestTags(t *testing.T) {
+	tag := NewTag("Static Sites")
+	assert.Equal(t, "static-sites", tag.Slug, "slug should be lowercase with whitespace replaced")
+	assert.Equal(t, "tags/static-sites.html", tag.URL, "tag page should be in the tag directory")
+
+	tags := NewTags([]string{ "C", "C++", "C#", "!!!", "index" })
+	assert.Equal(t, "c", tags[0].Slug)
+	assert.Regexp(t, "^c-[0-9a-f]{6}$", tags[1].Slug, "tags losing characters should get a hash")
+	assert.NotEqual(t, tags[1].Slug, tags[2].Slug)
+	assert.Regexp(t, "^[0-9a-f]{6}$", tags[3].Slug, "tags without a slug should get a hash")
+	assert.NotEqual(t, "tags/index.html", tags[4].URL, "tags should not replace the tag overview page")
+	assert.Equal(t, NewTag("c++").Slug, tags[1].Slug, "the slug should not depend on case")
+}
+
+func TestBuildTOC(t *testing.T) {
+	toc := BuildTOC([]blog.Heading{
+		{ Level: 1, ID: "title", Title: "Title" },
+		{ Level: 2, ID: "a", Title: "A" },
+		{ Level: 3, ID: "a-1", Title: "A.1" },
+		{ Level: 2, ID: "b", Title: "B & C" },
+		{ Level: 4, ID: "b-1", Title: "B.1" },
+		{ Level: 3, ID: "b-2", Title: "B.2" },
+	}, 2, 4)
+
+	require.Len(t, toc.Items, 2, "headings outside the level range should be left out")
+	assert.Equal(t, "#a", toc.Items[0].URL)
+	assert.Len(t, toc.Items[0].Children, 1, "lower level headings should be nested")
+	assert.Len(t, toc.Items[1].Children, 2, "skipped levels should still nest under the previous heading")
+	assert.Contains(t, string(toc.HTML), `<a href="#b">B &amp; C</a>`, "titles should be escaped")
+
+	assert.Empty(t, BuildTOC(nil, 2, 4).HTML, "there should be no HTML without headings")
+}
+
+func TestSharedTemplates(t *testing.T) {
+	file := func(s string) *fstest.MapFile {
+		return &fstest.MapFile{ Data: []byte(s) }
+	}
+
+	folder := fstest.MapFS{
+		"layouts/base.html": file(`{{define "base"}}<title>{{block "title" .}}Blog{{end}}</title>{{template "header" .}}{{block "content" .}}{{end}}{{end}}`),
+		"partials/header.html": file(`{{define "header"}}<h1>{{.}}</h1>{{end}}`),
+		FrontPageTemplateFileName: file(`{{template "base" .}}{{define "title"}}Front{{end}}{{define "content"}}front{{end}}`),
+		BlogPageTemplateFileName: file(`{{template "base" .}}{{define "content"}}post{{end}}`),
+		IndexPageTemplateFileName: file(`{{template "header" .}}`),
+	}
+
+	tmpl, err := LoadTemplate(folder)
+	require.NoError(t, err)
+
+	execute := func(page *template.Template) string {
+		var b strings.Builder
+		assert.NoError(t, page.Execute(&b, "x"))
+		return b.String()
+	}
+
+	assert.Equal(t, "<title>Front</title><h1>x</h1>front", execute(tmpl.FrontPage), "pages should override the blocks of layouts")
+	assert.Equal(t, "<title>Blog</title><h1>x</h1>post", execute(tmpl.BlogPage), "blocks defined by other pages should not leak")
+	assert.Equal(t, "<h1>x</h1>", execute(tmpl.IndexPage), "partials should be usable without a layout")
+
+	folder[BlogPageTemplateFileName] = file(`{{template "missing" .}}`)
+	tmpl, err = LoadTemplate(folder)
+	require.NoError(t, err)
+	assert.Error(t, tmpl.BlogPage.Execute(&strings.Builder{}, "x"))
+
+	folder["partials/broken.html"] = file(`{{define "broken"}}`)
+	_, err = LoadTemplate(folder)
+	assert.ErrorContains(t, err, "partials/broken.html", "errors should name the shared template")
+}
@@ -2,23 +2,27 @@ module github.com/aghorui/burlough
 
 go 1.20
 
-require github.com/yuin/goldmark v1.5.4
+require (
+	github.com/alecthomas/chroma/v2 v2.2.0
+	github.com/chzyer/readline v1.5.1
+	github.com/otiai10/copy v1.12.0
+	github.com/yuin/goldmark v1.5.4
+	golang.org/x/image v0.18.0
+	golang.org/x/net v0.35.0
+)
 
 require (
 	github.com/BurntSushi/toml v1.2.1 // indirect
-	github.com/alecthomas/chroma/v2 v2.2.0 // indirect
-	github.com/chzyer/readline v1.5.1 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dlclark/regexp2 v1.7.0 // indirect
-	github.com/otiai10/copy v1.12.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+	golang.org/x/sys v0.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 require (
 	github.com/stretchr/testify v1.8.4
+	github.com/yuin/goldmark-emoji v1.0.1
 	github.com/yuin/goldmark-highlighting/v2 v2.0.0-20220924101305-151362477c87
 	go.abhg.dev/goldmark/frontmatter v0.1.0
-	gopkg.in/yaml.v2 v2.3.0 // indirect
 )
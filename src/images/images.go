@@ -0,0 +1,231 @@
+package images
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aghorui/burlough/util"
+
+	"golang.org/x/image/draw"
+)
+
+// Quality of resized JPEG images.
+const jpegQuality = 85
+
+// A resized copy of an image.
+type Variant struct {
+	Width int
+	Height int
+	Path string // Path of the copy in the cache directory.
+}
+
+// Creates resized copies of PNG and JPEG images. Copies are cached by the
+// hash of the image and their width, so an image is only resized again when
+// it changes.
+type Processor struct {
+	Widths []int
+	CacheDir string
+}
+
+// Returns the directory resized images are cached in by default.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, "burlough", "images")
+}
+
+// Creates a processor making copies of the given widths, sorted and without
+// duplicates.
+func NewProcessor(widths []int, cacheDir string) *Processor {
+	sorted := make([]int, 0, len(widths))
+	seen := make(map[int]bool)
+
+	for _, w := range widths {
+		if w > 0 && !seen[w] {
+			seen[w] = true
+			sorted = append(sorted, w)
+		}
+	}
+
+	sort.Ints(sorted)
+
+	return &Processor{ Widths: sorted, CacheDir: cacheDir }
+}
+
+// Whether the dimensions of a file can be read, going by its extension.
+func IsImage(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return true
+	}
+
+	return false
+}
+
+// Whether resized copies can be made of a file, going by its extension.
+func IsResizable(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg":
+		return true
+	}
+
+	return false
+}
+
+// Returns the width and height of a PNG, JPEG or GIF image as shown, turned
+// as given by its EXIF orientation.
+func Size(path string) (int, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, util.Error(err)
+	}
+
+	return size(data)
+}
+
+func size(data []byte) (int, int, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if swapsDimensions(ReadMetadata(data).Orientation) {
+		return config.Height, config.Width, nil
+	}
+
+	return config.Width, config.Height, nil
+}
+
+// Returns the height of an image of the given size scaled to width.
+func scaledHeight(width int, height int, scaledWidth int) int {
+	h := (height * scaledWidth + width / 2) / width
+
+	if h < 1 {
+		return 1
+	}
+
+	return h
+}
+
+// Returns the resized copies of the image at path that are narrower than it,
+// narrowest first, making the ones that are not cached yet. Copies are turned
+// as given by the EXIF orientation of the image and have no metadata.
+func (p *Processor) Variants(path string) ([]Variant, error) {
+	variants := make([]Variant, 0)
+
+	if len(p.Widths) == 0 || !IsResizable(path) {
+		return variants, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	width, height, err := size(data)
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := ReadMetadata(data).Orientation
+	sum := sha1.Sum(data)
+	hash := hex.EncodeToString(sum[:])
+	ext := strings.ToLower(filepath.Ext(path))
+
+	var src image.Image
+
+	for _, w := range p.Widths {
+		if w >= width {
+			break
+		}
+
+		v := Variant{
+			Width: w,
+			Height: scaledHeight(width, height, w),
+			Path: filepath.Join(p.CacheDir, fmt.Sprintf("%v-%v%v", hash, w, ext)),
+		}
+
+		variants = append(variants, v)
+
+		if _, err := os.Stat(v.Path); err == nil {
+			continue
+		}
+
+		// Images are only decoded when a copy has to be made.
+		if src == nil {
+			src, _, err = image.Decode(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err = writeVariant(src, v, ext, orientation)
+		if err != nil {
+			return nil, fmt.Errorf("Error encountered while resizing %v: %w", path, err)
+		}
+	}
+
+	return variants, nil
+}
+
+// Scales an image to the size of a variant and writes it to the path of the
+// variant. The EXIF orientation of the image is applied, as copies have no
+// metadata.
+func writeVariant(src image.Image, v Variant, ext string, orientation int) error {
+	w, h := v.Width, v.Height
+
+	if swapsDimensions(orientation) {
+		w, h = h, w
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+	dst = orient(dst, orientation)
+
+	var buf bytes.Buffer
+	var err error
+
+	if ext == ".png" {
+		err = png.Encode(&buf, dst)
+	} else {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{ Quality: jpegQuality })
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(v.Path), 0755)
+	if err != nil {
+		return util.Error(err)
+	}
+
+	// Copies are written to a temporary file first so an interrupted render
+	// does not leave a broken copy in the cache.
+	tmp := v.Path + ".tmp"
+
+	err = os.WriteFile(tmp, buf.Bytes(), 0644)
+	if err != nil {
+		return util.Error(err)
+	}
+
+	err = os.Rename(tmp, v.Path)
+	if err != nil {
+		return util.Error(err)
+	}
+
+	return nil
+}
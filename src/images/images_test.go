@@ -0,0 +1,147 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePNG(t *testing.T, path string, width int, height int) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	require.NoError(t, png.Encode(f, image.NewNRGBA(image.Rect(0, 0, width, height))))
+}
+
+func TestVariants(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "shot.png")
+	writePNG(t, src, 200, 101)
+
+	p := NewProcessor([]int{ 400, 100, 50, 100 }, filepath.Join(dir, "cache"))
+	assert.Equal(t, []int{ 50, 100, 400 }, p.Widths, "widths should be sorted without duplicates")
+
+	width, height, err := Size(src)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, width)
+	assert.Equal(t, 101, height)
+
+	variants, err := p.Variants(src)
+	assert.NoError(t, err)
+	assert.Len(t, variants, 2, "no copies should be made wider than the image")
+	assert.Equal(t, 51, variants[1].Height, "copies should keep the aspect ratio")
+
+	w, h, err := Size(variants[0].Path)
+	assert.NoError(t, err)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 25, h)
+
+	info, err := os.Stat(variants[1].Path)
+	assert.NoError(t, err)
+
+	cached, err := p.Variants(src)
+	assert.NoError(t, err)
+	assert.Equal(t, variants, cached)
+
+	again, err := os.Stat(cached[1].Path)
+	assert.NoError(t, err)
+	assert.Equal(t, info.ModTime(), again.ModTime(), "cached copies should not be made again")
+
+	none, err := p.Variants(filepath.Join(dir, "drawing.svg"))
+	assert.NoError(t, err)
+	assert.Empty(t, none, "only PNG and JPEG images should be resized")
+}
+
+// Returns EXIF metadata with an orientation and a GPS latitude.
+func testEXIF(orientation byte) []byte {
+	return []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8,
+		0, 2,
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, orientation, 0, 0,
+		0x88, 0x25, 0, 4, 0, 0, 0, 1, 0, 0, 0, 38,
+		0, 0, 0, 0,
+		0, 1,
+		0x00, 0x02, 0, 5, 0, 0, 0, 3, 0, 0, 0, 0,
+		0, 0, 0, 0,
+	}
+}
+
+func TestStripMetadata(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 200, 100))
+
+	{
+		var buf bytes.Buffer
+		require.NoError(t, jpeg.Encode(&buf, img, nil))
+
+		exif := append([]byte("Exif\x00\x00"), testEXIF(6)...)
+		xmp := []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>GPSLatitude</x:xmpmeta>")
+		data := append([]byte{}, buf.Bytes()[:2]...)
+		data = append(data, 0xFF, 0xE1, 0, byte(len(exif) + 2))
+		data = append(data, exif...)
+		data = append(data, 0xFF, 0xE1, 0, byte(len(xmp) + 2))
+		data = append(data, xmp...)
+		data = append(data, buf.Bytes()[2:]...)
+
+		assert.Equal(t, Metadata{ Orientation: 6, Location: true }, ReadMetadata(data))
+
+		stripped, m, err := StripMetadata(data)
+		assert.NoError(t, err)
+		assert.True(t, m.Location)
+		assert.NotContains(t, string(stripped), "GPSLatitude", "XMP should be removed")
+		assert.Equal(t, Metadata{ Orientation: 6 }, ReadMetadata(stripped), "only the orientation should be kept")
+
+		_, err = jpeg.Decode(bytes.NewReader(stripped))
+		assert.NoError(t, err)
+
+		dir := t.TempDir()
+		src := filepath.Join(dir, "photo.jpg")
+		require.NoError(t, os.WriteFile(src, data, 0644))
+
+		w, h, err := Size(src)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{ 100, 200 }, []int{ w, h }, "turned images should have their dimensions swapped")
+
+		variants, err := NewProcessor([]int{ 50 }, dir).Variants(src)
+		assert.NoError(t, err)
+		w, h, err = Size(variants[0].Path)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{ 50, 100 }, []int{ w, h }, "copies should be turned")
+	}
+
+	{
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, img))
+
+		data := append([]byte{}, buf.Bytes()[:8]...)
+		data = append(data, pngChunk("eXIf", testEXIF(1))...)
+		data = append(data, pngChunk("tEXt", []byte("Comment\x00hello"))...)
+		data = append(data, buf.Bytes()[8:]...)
+
+		stripped, m, err := StripMetadata(data)
+		assert.NoError(t, err)
+		assert.Equal(t, Metadata{ Orientation: 1, Location: true }, m)
+		assert.NotContains(t, string(stripped), "eXIf")
+		assert.NotContains(t, string(stripped), "hello")
+
+		_, err = png.Decode(bytes.NewReader(stripped))
+		assert.NoError(t, err)
+	}
+
+	{
+		_, _, err := StripMetadata([]byte{ 0xFF, 0xD8, 0xFF, 0xE1, 0xFF })
+		assert.Equal(t, ErrMalformedImage, err)
+
+		data := []byte("<svg></svg>")
+		stripped, _, err := StripMetadata(data)
+		assert.NoError(t, err)
+		assert.Equal(t, data, stripped, "other files should be left as they are")
+	}
+}
@@ -0,0 +1,347 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+)
+
+var ErrMalformedImage = fmt.Errorf("Malformed image.")
+
+var jpegSignature = []byte{ 0xFF, 0xD8 }
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// Prefixes of the payloads of JPEG APP1 segments.
+var exifPrefix = []byte("Exif\x00\x00")
+var xmpPrefixes = [][]byte{
+	[]byte("http://ns.adobe.com/xap/1.0/\x00"),
+	[]byte("http://ns.adobe.com/xmp/extension/\x00"),
+}
+
+// Properties of XMP packets holding a location.
+var xmpLocationProperties = [][]byte{
+	[]byte("GPSLatitude"),
+	[]byte("GPSLongitude"),
+}
+
+const (
+	jpegAPP1 = 0xE1 // EXIF and XMP
+	jpegAPP13 = 0xED // Photoshop resources, including IPTC
+	jpegSOS = 0xDA  // Start of the image data
+	jpegEOI = 0xD9
+)
+
+const (
+	tagOrientation = 0x0112
+	tagGPSInfo = 0x8825
+	tagGPSLatitude = 0x0002
+	tagGPSLongitude = 0x0004
+)
+
+// Metadata of an image that matters for publishing it.
+type Metadata struct {
+	Orientation int // EXIF orientation. 1 if there is none.
+	Location bool   // Whether EXIF or XMP metadata holds GPS coordinates.
+}
+
+// Whether an EXIF orientation turns the image by 90 degrees, swapping its
+// width and height.
+func swapsDimensions(orientation int) bool {
+	return orientation >= 5 && orientation <= 8
+}
+
+// Calls fn with each entry of the TIFF IFD at offset.
+func tiffEntries(tiff []byte, order binary.ByteOrder, offset int, fn func(tag uint16, value []byte)) {
+	if offset < 8 || offset + 2 > len(tiff) {
+		return
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i * 12
+		if entry + 12 > len(tiff) {
+			return
+		}
+
+		fn(order.Uint16(tiff[entry:]), tiff[entry + 8:entry + 12])
+	}
+}
+
+// Reads the orientation and location from EXIF metadata, which is a TIFF
+// file. Unreadable metadata is ignored.
+func readEXIF(tiff []byte, m *Metadata) {
+	if len(tiff) < 8 {
+		return
+	}
+
+	var order binary.ByteOrder
+
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+
+	tiffEntries(tiff, order, int(order.Uint32(tiff[4:])), func(tag uint16, value []byte) {
+		switch tag {
+		case tagOrientation:
+			if o := int(order.Uint16(value)); o >= 1 && o <= 8 {
+				m.Orientation = o
+			}
+
+		case tagGPSInfo:
+			tiffEntries(tiff, order, int(order.Uint32(value)), func(tag uint16, value []byte) {
+				if tag == tagGPSLatitude || tag == tagGPSLongitude {
+					m.Location = true
+				}
+			})
+		}
+	})
+}
+
+// Reads the location from an XMP packet.
+func readXMP(packet []byte, m *Metadata) {
+	for _, property := range xmpLocationProperties {
+		if bytes.Contains(packet, property) {
+			m.Location = true
+		}
+	}
+}
+
+// Returns EXIF metadata holding only an orientation.
+func orientationEXIF(orientation int) []byte {
+	return []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8, // Header, with IFD0 right after it
+		0, 1,                        // One entry
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, byte(orientation), 0, 0, // Orientation, one SHORT
+		0, 0, 0, 0,                  // No next IFD
+	}
+}
+
+// Calls fn with the marker, start and end of each segment of a JPEG image
+// before the image data, and returns the offset the image data starts at.
+func jpegSegments(data []byte, fn func(marker byte, start int, end int)) (int, error) {
+	i := len(jpegSignature)
+
+	for {
+		if i + 1 >= len(data) || data[i] != 0xFF {
+			return 0, ErrMalformedImage
+		}
+
+		marker := data[i + 1]
+
+		switch {
+		case marker == 0xFF:
+			// Fill byte
+			i++
+			continue
+
+		case marker == jpegSOS || marker == jpegEOI:
+			return i, nil
+
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD8):
+			// Markers without a payload
+			fn(marker, i, i + 2)
+			i += 2
+			continue
+		}
+
+		if i + 3 >= len(data) {
+			return 0, ErrMalformedImage
+		}
+
+		end := i + 2 + (int(data[i + 2]) << 8 | int(data[i + 3]))
+		if end < i + 4 || end > len(data) {
+			return 0, ErrMalformedImage
+		}
+
+		fn(marker, i, end)
+		i = end
+	}
+}
+
+// Removes EXIF, XMP and Photoshop metadata from a JPEG image. The
+// orientation is kept in new EXIF metadata, so the image is not shown turned.
+func stripJPEG(data []byte) ([]byte, Metadata, error) {
+	m := Metadata{ Orientation: 1 }
+	out := make([]byte, 0, len(data))
+	out = append(out, jpegSignature...)
+	exifAt := -1
+
+	imageData, err := jpegSegments(data, func(marker byte, start int, end int) {
+		if marker != jpegAPP1 && marker != jpegAPP13 {
+			out = append(out, data[start:end]...)
+			return
+		}
+
+		payload := data[start + 4:end]
+
+		if marker == jpegAPP1 && bytes.HasPrefix(payload, exifPrefix) {
+			readEXIF(payload[len(exifPrefix):], &m)
+
+			if exifAt < 0 {
+				exifAt = len(out)
+			}
+		}
+
+		for _, prefix := range xmpPrefixes {
+			if marker == jpegAPP1 && bytes.HasPrefix(payload, prefix) {
+				readXMP(payload, &m)
+			}
+		}
+	})
+
+	if err != nil {
+		return nil, m, err
+	}
+
+	out = append(out, data[imageData:]...)
+
+	if m.Orientation != 1 {
+		payload := append(append([]byte{}, exifPrefix...), orientationEXIF(m.Orientation)...)
+		segment := append([]byte{ 0xFF, jpegAPP1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2) }, payload...)
+
+		out = append(out[:exifAt], append(segment, out[exifAt:]...)...)
+	}
+
+	return out, m, nil
+}
+
+// Calls fn with the type, start and end of each chunk of a PNG image, and
+// the start of its data.
+func pngChunks(data []byte, fn func(chunkType string, start int, dataStart int, end int)) error {
+	i := len(pngSignature)
+
+	for i < len(data) {
+		if i + 12 > len(data) {
+			return ErrMalformedImage
+		}
+
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+
+		if length < 0 || end > len(data) {
+			return ErrMalformedImage
+		}
+
+		fn(string(data[i + 4:i + 8]), i, i + 8, end)
+		i = end
+	}
+
+	return nil
+}
+
+// Returns a PNG chunk.
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := make([]byte, 8, 12 + len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], chunkType)
+	chunk = append(chunk, data...)
+
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// Removes EXIF metadata and text chunks, which hold XMP, from a PNG image.
+// The orientation is kept in a new EXIF chunk.
+func stripPNG(data []byte) ([]byte, Metadata, error) {
+	m := Metadata{ Orientation: 1 }
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+
+	err := pngChunks(data, func(chunkType string, start int, dataStart int, end int) {
+		switch chunkType {
+		case "eXIf":
+			readEXIF(data[dataStart:end - 4], &m)
+
+			if m.Orientation != 1 {
+				out = append(out, pngChunk("eXIf", orientationEXIF(m.Orientation))...)
+			}
+
+		case "iTXt", "tEXt", "zTXt":
+			readXMP(data[dataStart:end - 4], &m)
+
+		default:
+			out = append(out, data[start:end]...)
+		}
+	})
+
+	if err != nil {
+		return nil, m, err
+	}
+
+	return out, m, nil
+}
+
+// Removes metadata from a JPEG or PNG image, returning what it held. Other
+// files are returned as they are.
+func StripMetadata(data []byte) ([]byte, Metadata, error) {
+	switch {
+	case bytes.HasPrefix(data, jpegSignature):
+		return stripJPEG(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return stripPNG(data)
+	}
+
+	return data, Metadata{ Orientation: 1 }, nil
+}
+
+// Reads the metadata of a JPEG or PNG image.
+func ReadMetadata(data []byte) Metadata {
+	_, m, err := StripMetadata(data)
+	if err != nil {
+		return Metadata{ Orientation: 1 }
+	}
+
+	return m
+}
+
+// Turns and flips an image as given by its EXIF orientation.
+func orient(src *image.NRGBA, orientation int) *image.NRGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+
+	if swapsDimensions(orientation) {
+		dw, dh = h, w
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+
+			switch orientation {
+			case 2:
+				sx, sy = w - 1 - x, y
+			case 3:
+				sx, sy = w - 1 - x, h - 1 - y
+			case 4:
+				sx, sy = x, h - 1 - y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h - 1 - x
+			case 7:
+				sx, sy = w - 1 - y, h - 1 - x
+			case 8:
+				sx, sy = w - 1 - y, x
+			}
+
+			s := src.PixOffset(src.Bounds().Min.X + sx, src.Bounds().Min.Y + sy)
+			d := dst.PixOffset(x, y)
+			copy(dst.Pix[d:d + 4], src.Pix[s:s + 4])
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,314 @@
+package linkcheck
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aghorui/burlough/blog"
+	"github.com/aghorui/burlough/util"
+
+	"golang.org/x/net/html"
+)
+
+// Attributes holding URLs, by the elements they are checked on.
+var urlAttributes = map[string][]string{
+	"a": { "href" },
+	"area": { "href" },
+	"link": { "href" },
+	"img": { "src", "srcset" },
+	"source": { "src", "srcset" },
+	"script": { "src" },
+	"iframe": { "src" },
+	"video": { "src", "poster" },
+	"audio": { "src" },
+	"track": { "src" },
+	"embed": { "src" },
+}
+
+// A link in a page.
+type link struct {
+	url string
+	line int
+}
+
+// A crawled page.
+type page struct {
+	ids map[string]bool
+	links []link
+}
+
+// A blog file a page was rendered from.
+type Source struct {
+	Path string       // Path of the blog file.
+	Links []blog.Link // Links in the content of the blog file, as rendered.
+}
+
+// A link that does not resolve.
+type Problem struct {
+	Page string    // Page the link is in, relative to the checked directory.
+	Line int       // Line of the link in the page.
+	Source string  // Blog file the link is written in. Empty for links from the template.
+	SourceLine int // Line of the link in the blog file.
+	URL string
+	Reason string
+}
+
+func (p Problem) String() string {
+	if p.Source != "" {
+		return fmt.Sprintf("%v:%v: '%v': %v (in %v)", p.Source, p.SourceLine, p.URL, p.Reason, p.Page)
+	}
+
+	return fmt.Sprintf("%v:%v: '%v': %v", p.Page, p.Line, p.URL, p.Reason)
+}
+
+// Returns the URLs in a srcset attribute.
+func srcsetURLs(srcset string) []string {
+	urls := make([]string, 0)
+
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+
+		if len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+
+	return urls
+}
+
+// Collects the ids and links of an HTML page.
+func crawlPage(r io.Reader) (page, error) {
+	p := page{ ids: make(map[string]bool) }
+	line := 1
+	seen := make(map[link]bool)
+
+	// A URL is only collected once per line, even if it is in both the src
+	// and srcset of an image.
+	add := func(l link) {
+		if !seen[l] {
+			seen[l] = true
+			p.links = append(p.links, l)
+		}
+	}
+
+	z := html.NewTokenizer(r)
+
+	for {
+		tt := z.Next()
+
+		if tt == html.ErrorToken {
+			if z.Err() == io.EOF {
+				return p, nil
+			}
+
+			return p, z.Err()
+		}
+
+		start := line
+		line += bytes.Count(z.Raw(), []byte("\n"))
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		name, hasAttr := z.TagName()
+		attrs := urlAttributes[string(name)]
+
+		for hasAttr {
+			var key, val []byte
+			key, val, hasAttr = z.TagAttr()
+
+			switch {
+			case string(key) == "id":
+				p.ids[string(val)] = true
+
+			case string(key) == "name" && string(name) == "a":
+				p.ids[string(val)] = true
+
+			case string(key) == "srcset" && contains(attrs, "srcset"):
+				for _, u := range srcsetURLs(string(val)) {
+					add(link{ url: u, line: start })
+				}
+
+			case contains(attrs, string(key)):
+				add(link{ url: strings.TrimSpace(string(val)), line: start })
+			}
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks a link in the page at pagePath. Returns the reason it does not
+// resolve, or "" if it does.
+func checkLink(root string, pages map[string]page, pagePath string, u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "malformed URL"
+	}
+
+	// External links are not checked.
+	if parsed.Scheme != "" || parsed.Host != "" || parsed.Opaque != "" {
+		return ""
+	}
+
+	target := pagePath
+
+	if parsed.Path != "" {
+		if strings.HasPrefix(parsed.Path, "/") {
+			target = path.Clean(strings.TrimPrefix(parsed.Path, "/"))
+		} else {
+			target = path.Join(path.Dir(pagePath), parsed.Path)
+		}
+
+		if target == ".." || strings.HasPrefix(target, "../") {
+			return "points outside of the rendered blog"
+		}
+
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(target)))
+		if err != nil {
+			return "file does not exist"
+		}
+
+		if info.IsDir() {
+			target = path.Join(target, "index.html")
+
+			if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(target))); err != nil {
+				return "directory has no index.html"
+			}
+		}
+	}
+
+	if parsed.Fragment == "" || parsed.Fragment == "top" {
+		return ""
+	}
+
+	targetPage, ok := pages[target]
+	if !ok {
+		// Fragments are only checked on HTML pages.
+		return ""
+	}
+
+	if !targetPage.ids[parsed.Fragment] {
+		return fmt.Sprintf("no element with the id '%v'", parsed.Fragment)
+	}
+
+	return ""
+}
+
+// Crawls the HTML pages in a directory and checks that every relative link
+// points to an existing file, and that every fragment points to an element
+// of the linked page. sources maps pages to the blog files they were rendered
+// from. Broken links written in a blog file are reported with its line.
+// Problems are sorted by page and line.
+func Check(root string, sources map[string]Source) ([]Problem, error) {
+	pages := make(map[string]page)
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(p), ".html") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return util.Error(err)
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return util.Error(err)
+		}
+
+		defer f.Close()
+
+		crawled, err := crawlPage(f)
+		if err != nil {
+			return fmt.Errorf("Error encountered while reading %v: %w", p, err)
+		}
+
+		pages[filepath.ToSlash(rel)] = crawled
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	problems := make([]Problem, 0)
+
+	for pagePath, p := range pages {
+		source := sources[pagePath]
+		sourceLines := linesByURL(source.Links)
+
+		for _, l := range p.links {
+			reason := checkLink(root, pages, pagePath, l.url)
+			if reason == "" {
+				continue
+			}
+
+			problem := Problem{
+				Page: pagePath,
+				Line: l.line,
+				URL: l.url,
+				Reason: reason,
+			}
+
+			// Links are matched to the blog file in the order they appear.
+			// Anything else on the page comes from the template.
+			if lines := sourceLines[l.url]; len(lines) > 0 {
+				problem.Source = source.Path
+				problem.SourceLine = lines[0]
+				sourceLines[l.url] = lines[1:]
+			}
+
+			problems = append(problems, problem)
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Page != problems[j].Page {
+			return problems[i].Page < problems[j].Page
+		}
+
+		return problems[i].Line < problems[j].Line
+	})
+
+	return problems, nil
+}
+
+// Returns the lines of the links of a blog file by their URLs, without
+// repeating a URL on the same line.
+func linesByURL(links []blog.Link) map[string][]int {
+	lines := make(map[string][]int)
+	seen := make(map[blog.Link]bool)
+
+	for _, l := range links {
+		if !seen[l] {
+			seen[l] = true
+			lines[l.URL] = append(lines[l.URL], l.Line)
+		}
+	}
+
+	return lines
+}
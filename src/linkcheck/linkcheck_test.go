@@ -0,0 +1,55 @@
+package linkcheck
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aghorui/burlough/blog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name string, content string) {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	}
+
+	write("index.html", `<a href="post.html#intro">ok</a> <a href="https://example.com/missing">external</a>
+<a href="archive/">directory</a> <a href="#top">top</a>`)
+	write("archive/index.html", `<link href="../assets/main.css"><a href="../index.html">up</a>`)
+	write("assets/main.css", ``)
+	write("post.html", `<h2 id="intro">Intro</h2>
+<a href="post.html#outro">missing fragment</a>
+<img src="cat.png"
+	srcset="cat.png 1x, ../cat.png 2x">
+<a href="#intro">ok</a>
+<link href="missing.css">`)
+
+	source := Source{
+		Path: "post.md",
+		Links: []blog.Link{
+			{ URL: "post.html#outro", Line: 7 },
+			{ URL: "cat.png", Line: 9 },
+			{ URL: "cat.png", Line: 9 },
+			{ URL: "../cat.png", Line: 9 },
+		},
+	}
+
+	problems, err := Check(dir, map[string]Source{ "post.html": source })
+	require.NoError(t, err)
+
+	assert.Equal(t, []Problem{
+		{ Page: "post.html", Line: 2, Source: "post.md", SourceLine: 7, URL: "post.html#outro", Reason: "no element with the id 'outro'" },
+		{ Page: "post.html", Line: 3, Source: "post.md", SourceLine: 9, URL: "cat.png", Reason: "file does not exist" },
+		{ Page: "post.html", Line: 3, Source: "post.md", SourceLine: 9, URL: "../cat.png", Reason: "points outside of the rendered blog" },
+		{ Page: "post.html", Line: 6, URL: "missing.css", Reason: "file does not exist" },
+	}, problems, "broken links should be reported once, with the line of the blog file they are written in")
+
+	assert.Equal(t, "post.md:7: 'post.html#outro': no element with the id 'outro' (in post.html)", problems[0].String())
+	assert.Equal(t, "post.html:6: 'missing.css': file does not exist", problems[3].String())
+}
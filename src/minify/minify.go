@@ -0,0 +1,447 @@
+package minify
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Sizes of minified files before and after minifying them.
+type Stats struct {
+	Files int
+	Before int
+	After int
+}
+
+// Records a minified file.
+func (s *Stats) Add(before int, after int) {
+	s.Files++
+	s.Before += before
+	s.After += after
+}
+
+// Adds the files recorded in other.
+func (s *Stats) Merge(other Stats) {
+	s.Files += other.Files
+	s.Before += other.Before
+	s.After += other.After
+}
+
+func (s Stats) String() string {
+	saved := s.Before - s.After
+	percent := 0.0
+
+	if s.Before > 0 {
+		percent = float64(saved) * 100 / float64(s.Before)
+	}
+
+	return fmt.Sprintf("Minified %v files from %v to %v bytes, saving %v bytes (%.1f%%).",
+		s.Files, s.Before, s.After, saved, percent)
+}
+
+// Minifies an HTML, CSS or JS file, going by its extension. Returns false for
+// other files.
+func File(name string, data []byte) ([]byte, bool) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".html", ".htm":
+		return HTML(data), true
+	case ".css":
+		return CSS(data), true
+	case ".js", ".mjs":
+		return JS(data), true
+	}
+
+	return data, false
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// Replaces each run of whitespace with a newline if it has one, or a space.
+func collapseWhitespace(text []byte) []byte {
+	out := make([]byte, 0, len(text))
+
+	for i := 0; i < len(text); i++ {
+		if !isSpace(text[i]) {
+			out = append(out, text[i])
+			continue
+		}
+
+		sep := byte(' ')
+
+		for ; i < len(text) && isSpace(text[i]); i++ {
+			if text[i] == '\n' {
+				sep = '\n'
+			}
+		}
+
+		out = append(out, sep)
+		i--
+	}
+
+	return out
+}
+
+// Whether a <script> with the given type holds JavaScript.
+func isJavaScript(scriptType string) bool {
+	switch strings.ToLower(strings.TrimSpace(scriptType)) {
+	case "", "module", "text/javascript", "application/javascript":
+		return true
+	}
+
+	return false
+}
+
+// Minifies an HTML page by collapsing whitespace between and inside text and
+// removing comments. The contents of <pre> and <textarea> are kept as they
+// are, and inline CSS and JS are minified. Tags are not changed. Pages that
+// can not be read are returned as they are.
+func HTML(data []byte) []byte {
+	var out bytes.Buffer
+
+	z := html.NewTokenizer(bytes.NewReader(data))
+	preserve := 0   // Depth of <pre> elements.
+	rawText := ""   // Element the next text is the raw contents of.
+	scriptType := ""
+
+	for {
+		tt := z.Next()
+		raw := append([]byte(nil), z.Raw()...)
+
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return out.Bytes()
+			}
+
+			return data
+
+		case html.CommentToken:
+			// Conditional comments are not really comments.
+			if bytes.HasPrefix(raw, []byte("<!--[if")) {
+				out.Write(raw)
+			}
+
+		case html.TextToken:
+			switch {
+			case rawText == "style":
+				out.Write(CSS(raw))
+			case rawText == "script" && isJavaScript(scriptType):
+				out.Write(JS(raw))
+			case rawText != "" || preserve > 0:
+				out.Write(raw)
+			default:
+				text := collapseWhitespace(raw)
+
+				// Whitespace around removed comments is only written once.
+				if b := out.Bytes(); len(b) > 0 && isSpace(b[len(b) - 1]) && len(text) > 0 && isSpace(text[0]) {
+					text = text[1:]
+				}
+
+				out.Write(text)
+			}
+
+		case html.StartTagToken:
+			out.Write(raw)
+
+			name, hasAttr := z.TagName()
+			rawText = ""
+			scriptType = ""
+
+			switch string(name) {
+			case "pre":
+				preserve++
+			case "textarea", "title", "style", "script", "xmp":
+				rawText = string(name)
+			}
+
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+
+				if string(key) == "type" {
+					scriptType = string(val)
+				}
+			}
+
+		case html.EndTagToken:
+			out.Write(raw)
+
+			name, _ := z.TagName()
+			rawText = ""
+
+			if string(name) == "pre" && preserve > 0 {
+				preserve--
+			}
+
+		default:
+			out.Write(raw)
+		}
+	}
+}
+
+// Returns the index of the quote ending the string starting at i.
+func stringEnd(data []byte, i int) int {
+	quote := data[i]
+
+	for j := i + 1; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			j++
+		case quote:
+			return j
+		}
+	}
+
+	return len(data) - 1
+}
+
+// Minifies a stylesheet by removing comments, other than /*! ones, and
+// whitespace that does not separate anything. Strings are kept as they are.
+func CSS(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	space := false
+	separators := "{};,>"
+
+	last := func() byte {
+		if len(out) == 0 {
+			return '{'
+		}
+
+		return out[len(out) - 1]
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		switch {
+		case isSpace(c):
+			space = last() != '\n'
+			continue
+
+		case c == '/' && i + 1 < len(data) && data[i + 1] == '*':
+			end := bytes.Index(data[i + 2:], []byte("*/"))
+			if end < 0 {
+				end = len(data)
+			} else {
+				end += i + 4
+			}
+
+			// Kept comments end with a line break, which separates what is
+			// around them.
+			if i + 2 < len(data) && data[i + 2] == '!' {
+				out = append(out, data[i:end]...)
+				out = append(out, '\n')
+				space = false
+			} else {
+				space = true
+			}
+
+			i = end - 1
+			continue
+		}
+
+		if space && !strings.ContainsRune(separators + ":", rune(last())) && !strings.ContainsRune(separators, rune(c)) {
+			out = append(out, ' ')
+		}
+
+		space = false
+
+		switch c {
+		case '"', '\'':
+			end := stringEnd(data, i)
+			out = append(out, data[i:end + 1]...)
+			i = end
+
+		case '}':
+			if last() == ';' {
+				out = out[:len(out) - 1]
+			}
+
+			out = append(out, c)
+
+		default:
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+// Keywords a regular expression literal can follow.
+var regexKeywords = map[string]bool{
+	"return": true, "typeof": true, "case": true, "do": true, "else": true,
+	"in": true, "instanceof": true, "new": true, "delete": true, "void": true,
+	"throw": true, "yield": true, "await": true,
+}
+
+func isIdentifier(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// Returns the index of the backtick ending the template literal starting at
+// i, skipping the strings and template literals in its ${} expressions.
+func templateEnd(data []byte, i int) int {
+	depth := 0
+
+	for j := i + 1; j < len(data); j++ {
+		switch c := data[j]; {
+		case c == '\\':
+			j++
+		case depth == 0 && c == '`':
+			return j
+		case c == '$' && j + 1 < len(data) && data[j + 1] == '{':
+			depth++
+			j++
+		case depth > 0 && c == '{':
+			depth++
+		case depth > 0 && c == '}':
+			depth--
+		case depth > 0 && (c == '"' || c == '\''):
+			j = stringEnd(data, j)
+		case depth > 0 && c == '`':
+			j = templateEnd(data, j)
+		}
+	}
+
+	return len(data) - 1
+}
+
+// Returns the index of the slash ending the regular expression literal
+// starting at i.
+func regexEnd(data []byte, i int) int {
+	class := false
+
+	for j := i + 1; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			j++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '/':
+			if !class {
+				return j
+			}
+		case '\n':
+			return j - 1
+		}
+	}
+
+	return len(data) - 1
+}
+
+// Minifies a script by removing comments, other than /*! ones, indentation
+// and blank lines. Line breaks are kept, so statements ending without a
+// semicolon still end. Strings, template literals and regular expressions
+// are kept as they are.
+func JS(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	pending := byte(0) // Whitespace before the next token.
+	regexAllowed := true
+
+	last := func() byte {
+		if len(out) == 0 {
+			return '\n'
+		}
+
+		return out[len(out) - 1]
+	}
+
+	// Writes the pending whitespace if it separates something from c.
+	flush := func(c byte) {
+		switch {
+		case pending == '\n' && last() != '\n':
+			out = append(out, '\n')
+		case pending == ' ' && ((isIdentifier(last()) && isIdentifier(c)) ||
+			(last() == c && (c == '+' || c == '-' || c == '/'))):
+			out = append(out, ' ')
+		}
+
+		pending = 0
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		switch {
+		case c == '\n':
+			pending = '\n'
+
+		case isSpace(c):
+			if pending == 0 {
+				pending = ' '
+			}
+
+		case c == '/' && i + 1 < len(data) && data[i + 1] == '/':
+			for i + 1 < len(data) && data[i + 1] != '\n' {
+				i++
+			}
+
+		case c == '/' && i + 1 < len(data) && data[i + 1] == '*':
+			end := bytes.Index(data[i + 2:], []byte("*/"))
+			if end < 0 {
+				end = len(data)
+			} else {
+				end += i + 4
+			}
+
+			if i + 2 < len(data) && data[i + 2] == '!' {
+				flush(c)
+				out = append(out, data[i:end]...)
+				pending = '\n'
+			} else if bytes.IndexByte(data[i:end], '\n') >= 0 {
+				pending = '\n'
+			} else if pending == 0 {
+				pending = ' '
+			}
+
+			i = end - 1
+
+		case c == '"' || c == '\'' || c == '`' || (c == '/' && regexAllowed):
+			flush(c)
+
+			var end int
+
+			switch c {
+			case '`':
+				end = templateEnd(data, i)
+			case '/':
+				end = regexEnd(data, i)
+			default:
+				end = stringEnd(data, i)
+			}
+
+			out = append(out, data[i:end + 1]...)
+			i = end
+			regexAllowed = false
+
+		case isIdentifier(c):
+			end := i
+			for end < len(data) && isIdentifier(data[end]) {
+				end++
+			}
+
+			flush(c)
+			word := string(data[i:end])
+			out = append(out, word...)
+			i = end - 1
+			regexAllowed = regexKeywords[word]
+
+		default:
+			flush(c)
+			regexAllowed = !strings.ContainsRune(")]}.", rune(c)) &&
+				!((c == '+' || c == '-') && last() == c)
+			out = append(out, c)
+		}
+	}
+
+	return bytes.TrimSpace(out)
+}
@@ -0,0 +1,47 @@
+package minify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTML(t *testing.T) {
+	page := "<!DOCTYPE html>\n<html>\n\t<head>\n\t\t<!-- comment -->\n\t\t<title>A  title</title>\n" +
+		"\t\t<style>\n\t\t\tbody {\n\t\t\t\tmargin: 0;\n\t\t\t}\n\t\t</style>\n\t</head>\n" +
+		"\t<body>\n\t\t<p>Some   <b>bold</b>\n\t\t\ttext.</p>\n" +
+		"\t\t<pre><code>func main() {\n\t// Indented\n}\n</code></pre>\n" +
+		"\t\t<script type=\"application/ld+json\">{ \"a\":  1 }</script>\n" +
+		"\t\t<script>\n\t\t\tlet a = 1;  // one\n\t\t</script>\n\t</body>\n</html>\n"
+
+	assert.Equal(t, "<!DOCTYPE html>\n<html>\n<head>\n<title>A  title</title>\n" +
+		"<style>body{margin:0}</style>\n</head>\n" +
+		"<body>\n<p>Some <b>bold</b>\ntext.</p>\n" +
+		"<pre><code>func main() {\n\t// Indented\n}\n</code></pre>\n" +
+		"<script type=\"application/ld+json\">{ \"a\":  1 }</script>\n" +
+		"<script>let a=1;</script>\n</body>\n</html>\n", string(HTML([]byte(page))))
+}
+
+func TestCSS(t *testing.T) {
+	css := "/* comment */\n/*! License */\na > b,\nc :hover {\n\tcolor: red;\n\tcontent: \"a  ;  b\";\n" +
+		"\twidth: calc(100% - 2px);\n}\n\n@media (max-width: 600px) {\n\tp { margin: 0 auto; }\n}\n"
+
+	assert.Equal(t, "/*! License */\na>b,c :hover{color:red;content:\"a  ;  b\";width:calc(100% - 2px)}" +
+		"@media (max-width:600px){p{margin:0 auto}}", string(CSS([]byte(css))))
+}
+
+func TestJS(t *testing.T) {
+	js := "// comment\nconst a = 1 / 2   /* half */\n\n\tlet re = /[/]\\/ +/g;\n" +
+		"let s = `a  ${ `b  ${ \"}\" }` }  c`;\nreturn a + +b\nlet url = \"http://x\" // trailing\n"
+
+	assert.Equal(t, "const a=1/2\nlet re=/[/]\\/ +/g;\n" +
+		"let s=`a  ${ `b  ${ \"}\" }` }  c`;\nreturn a+ +b\nlet url=\"http://x\"", string(JS([]byte(js))))
+}
+
+func TestStats(t *testing.T) {
+	var s Stats
+	s.Add(100, 75)
+	s.Merge(Stats{ Files: 1, Before: 100, After: 75 })
+
+	assert.Equal(t, "Minified 2 files from 200 to 150 bytes, saving 50 bytes (25.0%).", s.String())
+}
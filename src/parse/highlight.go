@@ -0,0 +1,99 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/aghorui/burlough/blog"
+	"github.com/aghorui/burlough/util"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Name of the stylesheet generated for class based highlighting. It is
+// written to the assets directory of the rendered blog.
+const SyntaxStylesheetFileName = "syntax.css"
+
+// Chroma puts this style attribute on linkable line numbers even when using
+// classes. It is replaced by lineNumberLinkCSS in the generated stylesheet.
+const lineNumberLinkStyle = ` style="outline: none; text-decoration:none; color:inherit"`
+
+const lineNumberLinkCSS = "/* LineNumberLinks */ .chroma .ln a, .chroma .lnt a { outline: none; text-decoration: none; color: inherit }\n"
+
+// Returns the names of all available highlight styles, sorted.
+func HighlightStyles() []string {
+	return styles.Names()
+}
+
+// Returns the formatter options shared by code blocks and the generated
+// stylesheet.
+func formatterOptions(params blog.MarkdownParams) []chromahtml.Option {
+	return []chromahtml.Option{
+		chromahtml.WithClasses(params.HighlightClasses),
+		chromahtml.WithLineNumbers(params.LineNumbers),
+		chromahtml.LinkableLineNumbers(params.LineNumbers, "ln_"),
+	}
+}
+
+// Returns the CSS rules of a highlight style for class based highlighting.
+func StyleCSS(name string, params blog.MarkdownParams) ([]byte, error) {
+	style, ok := styles.Registry[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown highlight style '%v'", name)
+	}
+
+	params.HighlightClasses = true
+
+	var buf bytes.Buffer
+
+	err := chromahtml.New(formatterOptions(params)...).WriteCSS(&buf, style)
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	buf.WriteString(lineNumberLinkCSS)
+
+	return buf.Bytes(), nil
+}
+
+// Returns the stylesheet for class based highlighting. It uses the highlight
+// style, and the dark highlight style if set when the reader prefers a dark
+// color scheme.
+func SyntaxCSS(params blog.MarkdownParams) ([]byte, error) {
+	if params.HighlightStyle == "" {
+		params.HighlightStyle = blog.DefaultConfigFileParams().Markdown.HighlightStyle
+	}
+
+	light, err := StyleCSS(params.HighlightStyle, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.HighlightDarkStyle == "" {
+		return light, nil
+	}
+
+	dark, err := StyleCSS(params.HighlightDarkStyle, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	buf.Write(light)
+	buf.WriteString("\n@media (prefers-color-scheme: dark) {\n")
+	buf.Write(dark)
+	buf.WriteString("}\n")
+
+	return buf.Bytes(), nil
+}
+
+// Removes inline styles left in rendered HTML when highlighting with classes.
+func (p *Parser) postProcess(html []byte) []byte {
+	if !p.classes {
+		return html
+	}
+
+	return bytes.ReplaceAll(html, []byte(lineNumberLinkStyle), nil)
+}
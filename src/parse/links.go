@@ -0,0 +1,197 @@
+package parse
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/aghorui/burlough/blog"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	gutil "github.com/yuin/goldmark/util"
+)
+
+// Resolves the path of a linked markdown file, as written in the link, to the
+// URL the link should point to instead.
+type LinkResolver func(target string) (string, error)
+
+// An attribute added to the <img> tag of an image.
+type ImageAttribute struct {
+	Name string
+	Value string
+}
+
+// Returns the attributes to add to the <img> tag of a local image, such as
+// srcset and its dimensions, given its path as written in the image.
+type ImageResolver func(target string) ([]ImageAttribute, error)
+
+// Returns the line a node starts at, or 0 if it is not known.
+func nodeLine(n ast.Node, src []byte) int {
+	for c := n; c != nil; c = c.Parent() {
+		if c.Type() == ast.TypeBlock {
+			if c.Lines().Len() > 0 {
+				return lineAt(src, c.Lines().At(0).Start)
+			}
+
+			continue
+		}
+
+		var start = -1
+
+		_ = ast.Walk(c, func(d ast.Node, entering bool) (ast.WalkStatus, error) {
+			if t, ok := d.(*ast.Text); ok && entering {
+				start = t.Segment.Start
+				return ast.WalkStop, nil
+			}
+
+			return ast.WalkContinue, nil
+		})
+
+		if start >= 0 {
+			return lineAt(src, start)
+		}
+	}
+
+	return 0
+}
+
+// Resolvers used when rendering a document. A nil resolver leaves what it
+// would resolve as it is.
+type Resolvers struct {
+	Links LinkResolver       // Resolves links to markdown files.
+	WikiLinks LinkResolver   // Resolves the targets of wiki links.
+	Resources LinkResolver   // Resolves local files used by images and shortcodes.
+	Attachments LinkResolver // Resolves links to local files other than pages.
+	Images ImageResolver     // Adds attributes to local images.
+}
+
+// Returns the path of the local file a link destination points to, or "" if
+// it points elsewhere.
+func localLinkTarget(dest string) (string, *url.URL) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" || u.Path == "" {
+		return "", nil
+	}
+
+	return u.Path, u
+}
+
+// Whether a link points to a page rather than to a file that needs to be
+// copied along with the post.
+func isPageLink(target string) bool {
+	ext := strings.ToLower(path.Ext(target))
+	return ext == ".html" || ext == ".htm" || strings.HasSuffix(target, "/")
+}
+
+// Rewrites a link destination with a resolver, keeping its query and
+// fragment. Errors are recorded with the line of the node.
+func resolveDestination(dest []byte, resolver LinkResolver, node ast.Node, src []byte, pc parser.Context) []byte {
+	target, u := localLinkTarget(string(dest))
+	if target == "" || resolver == nil {
+		return dest
+	}
+
+	resolved, err := resolver(target)
+	if err != nil {
+		recordError(pc, nodeLine(node, src), err)
+		return dest
+	}
+
+	u.Path = ""
+	u.RawPath = ""
+
+	return []byte(resolved + u.String())
+}
+
+// Rewrites the destinations of links to markdown files and local files, adds
+// attributes to local images, and gives shortcodes the resource resolver.
+// Links to other local files go through the attachment resolver.
+func resolveLinks(doc ast.Node, src []byte, r Resolvers, pc parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node := n.(type) {
+		case *ast.Link:
+			target, _ := localLinkTarget(string(node.Destination))
+
+			switch {
+			case target == "" || isPageLink(target):
+			case strings.EqualFold(path.Ext(target), ".md"):
+				node.Destination = resolveDestination(node.Destination, r.Links, node, src, pc)
+			default:
+				node.Destination = resolveDestination(node.Destination, r.Attachments, node, src, pc)
+			}
+
+		case *ast.Image:
+			target, _ := localLinkTarget(string(node.Destination))
+			node.Destination = resolveDestination(node.Destination, r.Resources, node, src, pc)
+
+			if target != "" && r.Images != nil {
+				attrs, err := r.Images(target)
+				if err != nil {
+					recordError(pc, nodeLine(node, src), err)
+					break
+				}
+
+				for _, attr := range attrs {
+					node.SetAttributeString(attr.Name, []byte(attr.Value))
+				}
+			}
+
+		case *Shortcode:
+			node.resources = r.Resources
+
+		case *ShortcodeBlock:
+			node.resources = r.Resources
+		}
+
+		return ast.WalkContinue, nil
+	})
+}
+
+// Collects the destinations of the links and images of a document, as they
+// are rendered, along with their lines. The URLs in the srcset of images are
+// included.
+func collectLinks(doc ast.Node, src []byte) []blog.Link {
+	links := make([]blog.Link, 0)
+
+	add := func(dest []byte, n ast.Node) {
+		if len(dest) > 0 {
+			links = append(links, blog.Link{ URL: string(gutil.URLEscape(dest, true)), Line: nodeLine(n, src) })
+		}
+	}
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node := n.(type) {
+		case *ast.Link:
+			add(node.Destination, node)
+
+		case *WikiLink:
+			add(node.Destination, node)
+
+		case *ast.Image:
+			add(node.Destination, node)
+
+			if v, ok := node.AttributeString("srcset"); ok {
+				srcset, _ := v.([]byte)
+
+				for _, candidate := range strings.Split(string(srcset), ",") {
+					if fields := strings.Fields(candidate); len(fields) > 0 {
+						links = append(links, blog.Link{ URL: fields[0], Line: nodeLine(node, src) })
+					}
+				}
+			}
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return links
+}
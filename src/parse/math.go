@@ -0,0 +1,320 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"html"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	gutil "github.com/yuin/goldmark/util"
+)
+
+// Kind of Math nodes.
+var KindMath = ast.NewNodeKind("Math")
+
+// Kind of MathBlock nodes.
+var KindMathBlock = ast.NewNodeKind("MathBlock")
+
+// Inline math, written as $...$ or $$...$$ within a paragraph.
+type Math struct {
+	ast.BaseInline
+	TeX string
+	MathML string // Empty if the TeX could not be converted.
+	Display bool
+}
+
+func (n *Math) Kind() ast.NodeKind {
+	return KindMath
+}
+
+func (n *Math) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{ "TeX": n.TeX }, nil)
+}
+
+// Display math, written as a block between $$ lines.
+type MathBlock struct {
+	ast.BaseBlock
+	MathML string // Empty if the TeX could not be converted.
+	start int     // Offset of the opening $$ in the source.
+	closed bool
+}
+
+func (n *MathBlock) Kind() ast.NodeKind {
+	return KindMathBlock
+}
+
+func (n *MathBlock) IsRaw() bool {
+	return true
+}
+
+func (n *MathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// Converts TeX starting at offset in the source. Conversion errors are
+// recorded in the parser context with the line they occurred in.
+func convertMath(src []byte, offset int, tex string, display bool, pc parser.Context) string {
+	mathML, err := TeXToMathML(tex, display)
+	if err == nil {
+		return mathML
+	}
+
+	line := lineAt(src, offset)
+
+	var texErr *texError
+	if errors.As(err, &texErr) {
+		line += bytes.Count([]byte(tex[:texErr.offset]), []byte("\n"))
+	}
+
+	recordError(pc, line, err)
+
+	return ""
+}
+
+type mathInlineParser struct{}
+
+func (p *mathInlineParser) Trigger() []byte {
+	return []byte{ '$' }
+}
+
+func isSpaceByte(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+func (p *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+
+	display := len(line) > 1 && line[1] == '$'
+	opener := 1
+
+	if display {
+		opener = 2
+	} else if len(line) < 2 || isSpaceByte(line[1]) {
+		// "$ " is not math, so that prices like "$5 and $ 10" are left alone.
+		return nil
+	}
+
+	start := segment.Start + opener
+	l, pos := block.Position()
+	block.Advance(opener)
+
+	tex := make([]byte, 0)
+
+	for {
+		line, _ := block.PeekLine()
+		if line == nil {
+			block.SetPosition(l, pos)
+			return nil
+		}
+
+		for i := 0; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+
+			case '$':
+				if display {
+					if i + 1 < len(line) && line[i + 1] == '$' {
+						tex = append(tex, line[:i]...)
+						block.Advance(i + 2)
+						goto found
+					}
+
+					continue
+				}
+
+				// The closing $ must follow a non-space character and must
+				// not be followed by a digit.
+				content := append(tex, line[:i]...)
+
+				if len(content) == 0 || isSpaceByte(content[len(content) - 1]) {
+					continue
+				}
+
+				if i + 1 < len(line) && isDigit(line[i + 1]) {
+					continue
+				}
+
+				tex = content
+				block.Advance(i + 1)
+				goto found
+			}
+		}
+
+		tex = append(tex, line...)
+		block.AdvanceLine()
+	}
+
+found:
+	return &Math{
+		TeX: string(tex),
+		MathML: convertMath(block.Source(), start, string(tex), display, pc),
+		Display: display,
+	}
+}
+
+type mathBlockParser struct{}
+
+func (p *mathBlockParser) Trigger() []byte {
+	return []byte{ '$' }
+}
+
+func (p *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+
+	node := &MathBlock{ start: segment.Start + pos }
+	rest := line[pos + 2:]
+	restStart := segment.Start + pos + 2
+
+	// "$$ ... $$" on a single line.
+	if end := bytes.Index(rest, []byte("$$")); end >= 0 {
+		if !gutil.IsBlank(rest[end + 2:]) {
+			return nil, parser.NoChildren
+		}
+
+		node.Lines().Append(text.NewSegment(restStart, restStart + end))
+		node.closed = true
+		reader.Advance(segment.Len() - 1)
+
+		return node, parser.NoChildren
+	}
+
+	if !gutil.IsBlank(rest) {
+		node.Lines().Append(text.NewSegment(restStart, segment.Stop))
+	}
+
+	reader.Advance(segment.Len() - 1)
+
+	return node, parser.NoChildren
+}
+
+func (p *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*MathBlock)
+
+	if n.closed {
+		return parser.Close
+	}
+
+	line, segment := reader.PeekLine()
+	if line == nil {
+		return parser.Close
+	}
+
+	if end := bytes.Index(line, []byte("$$")); end >= 0 && gutil.IsBlank(line[end + 2:]) {
+		if !gutil.IsBlank(line[:end]) {
+			n.Lines().Append(text.NewSegment(segment.Start, segment.Start + end))
+		}
+
+		n.closed = true
+		reader.Advance(segment.Len() - 1)
+
+		return parser.Close
+	}
+
+	n.Lines().Append(segment)
+	reader.Advance(segment.Len() - 1)
+
+	return parser.Continue | parser.NoChildren
+}
+
+func (p *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {
+	n := node.(*MathBlock)
+	src := reader.Source()
+	lines := n.Lines()
+
+	var tex bytes.Buffer
+
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		tex.Write(line.Value(src))
+	}
+
+	offset := 0
+
+	if lines.Len() > 0 {
+		offset = lines.At(0).Start
+	}
+
+	if !n.closed {
+		recordError(pc, lineAt(src, n.start), errors.New("missing closing $$"))
+		return
+	}
+
+	n.MathML = convertMath(src, offset, tex.String(), true, pc)
+}
+
+func (p *mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (p *mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type mathRenderer struct{}
+
+func (r *mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMath, r.renderMath)
+	reg.Register(KindMathBlock, r.renderMathBlock)
+}
+
+func (r *mathRenderer) renderMath(w gutil.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*Math)
+
+	if n.MathML == "" {
+		_, _ = w.WriteString(`<code class="math-error">` + html.EscapeString(n.TeX) + "</code>")
+	} else {
+		_, _ = w.WriteString(n.MathML)
+	}
+
+	return ast.WalkContinue, nil
+}
+
+func (r *mathRenderer) renderMathBlock(w gutil.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*MathBlock)
+
+	if n.MathML == "" {
+		_, _ = w.WriteString(`<pre class="math-error"><code>`)
+
+		for i := 0; i < n.Lines().Len(); i++ {
+			line := n.Lines().At(i)
+			_, _ = w.WriteString(html.EscapeString(string(line.Value(source))))
+		}
+
+		_, _ = w.WriteString("</code></pre>\n")
+	} else {
+		_, _ = w.WriteString(n.MathML + "\n")
+	}
+
+	return ast.WalkContinue, nil
+}
+
+// Extension for $...$ and $$...$$ math, rendered as MathML.
+type mathExtender struct{}
+
+func (e *mathExtender) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(gutil.Prioritized(&mathBlockParser{}, 750)),
+		parser.WithInlineParsers(gutil.Prioritized(&mathInlineParser{}, 500)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(gutil.Prioritized(&mathRenderer{}, 500)),
+	)
+}
@@ -0,0 +1,904 @@
+package parse
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An error in TeX math. offset is the byte offset in the TeX source where the
+// problem was found.
+type texError struct {
+	offset int
+	msg string
+}
+
+func (e *texError) Error() string {
+	return e.msg
+}
+
+// A converted piece of math, along with whether scripts attached to it go
+// above and below it in display mode (e.g. \sum, \lim).
+type mathAtom struct {
+	ml string
+	limits bool
+}
+
+// Converts TeX math into MathML. Only the commonly used subset of TeX math is
+// supported: letters, numbers, operators, scripts, fractions, roots,
+// accents, fonts, delimiters and matrix-like environments.
+type texConverter struct {
+	src string
+	pos int
+	limit int         // End of the part of src being converted.
+	display bool
+	variant string    // Font of letters and digits ("", "normal", "bold", ...)
+}
+
+// Converts TeX math into a <math> element. The TeX source is kept as an
+// annotation.
+func TeXToMathML(tex string, display bool) (string, error) {
+	c := &texConverter{
+		src: tex,
+		limit: len(tex),
+		display: display,
+	}
+
+	items, err := c.parseList()
+	if err != nil {
+		return "", err
+	}
+
+	if !c.eof() {
+		return "", c.unexpected()
+	}
+
+	mode := "inline"
+
+	if display {
+		mode = "block"
+	}
+
+	return `<math xmlns="http://www.w3.org/1998/Math/MathML" display="` + mode + `"><semantics>` +
+		mrow(items) +
+		`<annotation encoding="application/x-tex">` + html.EscapeString(strings.TrimSpace(tex)) + `</annotation>` +
+		`</semantics></math>`, nil
+}
+
+func mrow(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+
+	return "<mrow>" + strings.Join(items, "") + "</mrow>"
+}
+
+func element(name string, attrs string, content string) string {
+	return "<" + name + attrs + ">" + content + "</" + name + ">"
+}
+
+func mo(s string) string {
+	return element("mo", "", html.EscapeString(s))
+}
+
+func mspace(width string) string {
+	return `<mspace width="` + width + `"/>`
+}
+
+func (c *texConverter) errorAt(offset int, format string, a ...any) error {
+	return &texError{ offset: offset, msg: fmt.Sprintf(format, a...) }
+}
+
+func (c *texConverter) unexpected() error {
+	switch {
+	case c.peek() == '}':
+		return c.errorAt(c.pos, "unexpected '}'")
+	case c.peek() == '&':
+		return c.errorAt(c.pos, "'&' can only be used inside environments")
+	case c.hasPrefix(`\\`):
+		return c.errorAt(c.pos, `'\\' can only be used inside environments`)
+	case c.hasCommand("right"):
+		return c.errorAt(c.pos, `\right without \left`)
+	case c.hasCommand("end"):
+		return c.errorAt(c.pos, `\end without \begin`)
+	}
+
+	return c.errorAt(c.pos, "unexpected '%v'", string(c.peek()))
+}
+
+func (c *texConverter) eof() bool {
+	return c.pos >= c.limit
+}
+
+func (c *texConverter) peek() byte {
+	if c.eof() {
+		return 0
+	}
+
+	return c.src[c.pos]
+}
+
+func (c *texConverter) hasPrefix(s string) bool {
+	return strings.HasPrefix(c.src[c.pos:c.limit], s)
+}
+
+// Whether the next token is the given command, e.g. hasCommand("end") for
+// "\end".
+func (c *texConverter) hasCommand(name string) bool {
+	if !c.hasPrefix(`\` + name) {
+		return false
+	}
+
+	next := c.pos + 1 + len(name)
+
+	return next >= c.limit || !isASCIILetter(c.src[next])
+}
+
+func (c *texConverter) skipSpace() {
+	for !c.eof() {
+		switch c.src[c.pos] {
+		case ' ', '\t', '\n', '\r':
+			c.pos++
+		case '%':
+			// Comments run until the end of the line.
+			for !c.eof() && c.src[c.pos] != '\n' {
+				c.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func isASCIILetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// Whether the converter is at the end of a list: the end of a group, cell,
+// row or delimited part.
+func (c *texConverter) atTerminator() bool {
+	return c.peek() == '}' ||
+		c.peek() == '&' ||
+		c.hasPrefix(`\\`) ||
+		c.hasCommand("end") ||
+		c.hasCommand("right")
+}
+
+// Converts everything up to the next terminator.
+func (c *texConverter) parseList() ([]string, error) {
+	items := make([]string, 0)
+
+	for {
+		c.skipSpace()
+
+		if c.eof() || c.atTerminator() {
+			return items, nil
+		}
+
+		atom, err := c.parseAtom(false)
+		if err != nil {
+			return nil, err
+		}
+
+		ml, err := c.parseScripts(atom)
+		if err != nil {
+			return nil, err
+		}
+
+		if ml != "" {
+			items = append(items, ml)
+		}
+	}
+}
+
+// Converts a braced group. The converter must be at the opening brace.
+func (c *texConverter) parseGroup() (string, error) {
+	start := c.pos
+	c.pos++
+
+	items, err := c.parseList()
+	if err != nil {
+		return "", err
+	}
+
+	if c.peek() != '}' {
+		if c.eof() {
+			return "", c.errorAt(start, "missing '}'")
+		}
+
+		return "", c.unexpected()
+	}
+
+	c.pos++
+
+	return mrow(items), nil
+}
+
+// Converts the argument of a command or script: a braced group, a command or
+// a single character.
+func (c *texConverter) parseArg(of string) (string, error) {
+	c.skipSpace()
+
+	if c.eof() || c.atTerminator() {
+		return "", c.errorAt(c.pos, "missing argument for %v", of)
+	}
+
+	atom, err := c.parseAtom(true)
+	if err != nil {
+		return "", err
+	}
+
+	if atom.ml == "" {
+		return "<mrow></mrow>", nil
+	}
+
+	return atom.ml, nil
+}
+
+// Reads a braced argument as raw text, e.g. for \text.
+func (c *texConverter) parseRawArg(of string) (string, error) {
+	c.skipSpace()
+
+	if c.peek() != '{' {
+		return "", c.errorAt(c.pos, "missing argument for %v", of)
+	}
+
+	start := c.pos
+	depth := 0
+
+	for i := c.pos; i < c.limit; i++ {
+		switch c.src[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+
+			if depth == 0 {
+				c.pos = i + 1
+				return c.src[start + 1:i], nil
+			}
+		}
+	}
+
+	return "", c.errorAt(start, "missing '}'")
+}
+
+// Converts a single token. With single set, numbers are split into digits as
+// TeX does for arguments ("x^12" is "x^{1}2").
+func (c *texConverter) parseAtom(single bool) (mathAtom, error) {
+	ch := c.peek()
+
+	switch {
+	case ch == '{':
+		ml, err := c.parseGroup()
+		return mathAtom{ ml: ml }, err
+
+	case ch == '\\':
+		return c.parseCommand()
+
+	case ch == '^' || ch == '_' || ch == '\'':
+		// A script without a base. parseScripts takes care of it.
+		return mathAtom{}, nil
+
+	case isASCIILetter(ch):
+		c.pos++
+		return mathAtom{ ml: c.identifier(rune(ch)) }, nil
+
+	case isDigit(ch) || (ch == '.' && c.pos + 1 < c.limit && isDigit(c.src[c.pos + 1])):
+		start := c.pos
+		c.pos++
+
+		for !single && !c.eof() && (isDigit(c.peek()) || (c.peek() == '.' && c.pos + 1 < c.limit && isDigit(c.src[c.pos + 1]))) {
+			c.pos++
+		}
+
+		return mathAtom{ ml: c.number(c.src[start:c.pos]) }, nil
+
+	case ch == '~':
+		c.pos++
+		return mathAtom{ ml: mspace("0.333em") }, nil
+
+	case ch == '#' || ch == '$':
+		return mathAtom{}, c.errorAt(c.pos, "unexpected '%v'", string(ch))
+	}
+
+	r, size := utf8.DecodeRuneInString(c.src[c.pos:c.limit])
+	c.pos += size
+
+	if unicode.IsLetter(r) {
+		return mathAtom{ ml: c.identifier(r) }, nil
+	}
+
+	switch r {
+	case '-':
+		return mathAtom{ ml: mo("−") }, nil
+	case '*':
+		return mathAtom{ ml: mo("∗") }, nil
+	}
+
+	return mathAtom{ ml: mo(string(r)) }, nil
+}
+
+// Converts superscripts, subscripts and primes following a base.
+func (c *texConverter) parseScripts(base mathAtom) (string, error) {
+	var sub string
+	var sup []string
+	hasSub := false
+	hasSup := false
+
+	for {
+		c.skipSpace()
+
+		switch {
+		case c.hasCommand("limits"):
+			c.pos += len(`\limits`)
+			base.limits = true
+			continue
+
+		case c.hasCommand("nolimits"):
+			c.pos += len(`\nolimits`)
+			base.limits = false
+			continue
+
+		case c.peek() == '\'':
+			if hasSup {
+				return "", c.errorAt(c.pos, "double superscript")
+			}
+
+			for c.peek() == '\'' {
+				sup = append(sup, mo("′"))
+				c.pos++
+			}
+
+			continue
+
+		case c.peek() == '^':
+			start := c.pos
+			c.pos++
+
+			if hasSup {
+				return "", c.errorAt(start, "double superscript")
+			}
+
+			arg, err := c.parseArg("'^'")
+			if err != nil {
+				return "", err
+			}
+
+			sup = append(sup, arg)
+			hasSup = true
+			continue
+
+		case c.peek() == '_':
+			start := c.pos
+			c.pos++
+
+			if hasSub {
+				return "", c.errorAt(start, "double subscript")
+			}
+
+			arg, err := c.parseArg("'_'")
+			if err != nil {
+				return "", err
+			}
+
+			sub = arg
+			hasSub = true
+			continue
+		}
+
+		break
+	}
+
+	if !hasSub && len(sup) == 0 {
+		return base.ml, nil
+	}
+
+	baseML := base.ml
+
+	if baseML == "" {
+		baseML = "<mrow></mrow>"
+	}
+
+	under, over := "msub", "msup"
+	both := "msubsup"
+
+	if base.limits && c.display {
+		under, over, both = "munder", "mover", "munderover"
+	}
+
+	switch {
+	case hasSub && len(sup) > 0:
+		return element(both, "", baseML + sub + mrow(sup)), nil
+	case hasSub:
+		return element(under, "", baseML + sub), nil
+	default:
+		return element(over, "", baseML + mrow(sup)), nil
+	}
+}
+
+// Converts a letter in the current font.
+func (c *texConverter) identifier(r rune) string {
+	s := html.EscapeString(string(r))
+
+	switch c.variant {
+	case "":
+		return element("mi", "", s)
+	case "normal":
+		return element("mi", ` mathvariant="normal"`, s)
+	}
+
+	if mapped, ok := mathAlphanumeric(c.variant, r); ok {
+		return element("mi", "", string(mapped))
+	}
+
+	return element("mi", ` mathvariant="` + c.variant + `"`, s)
+}
+
+// Converts a number in the current font.
+func (c *texConverter) number(s string) string {
+	if c.variant == "" || c.variant == "normal" {
+		return element("mn", "", s)
+	}
+
+	var b strings.Builder
+
+	for _, r := range s {
+		if mapped, ok := mathAlphanumeric(c.variant, r); ok {
+			b.WriteRune(mapped)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+
+	return element("mn", "", b.String())
+}
+
+// Reads the name of a command. The converter must be at the backslash.
+func (c *texConverter) commandName() string {
+	c.pos++
+
+	if c.eof() {
+		return ""
+	}
+
+	start := c.pos
+
+	if !isASCIILetter(c.src[c.pos]) {
+		// Control symbols such as \{ or \, are a single character.
+		c.pos++
+		return c.src[start:c.pos]
+	}
+
+	for !c.eof() && isASCIILetter(c.src[c.pos]) {
+		c.pos++
+	}
+
+	return c.src[start:c.pos]
+}
+
+func (c *texConverter) parseCommand() (mathAtom, error) {
+	start := c.pos
+	name := c.commandName()
+
+	if name == "" {
+		return mathAtom{}, c.errorAt(start, `unexpected '\' at the end of math`)
+	}
+
+	if s, ok := texIdentifiers[name]; ok {
+		return mathAtom{ ml: element("mi", "", s) }, nil
+	}
+
+	if s, ok := texUprightIdentifiers[name]; ok {
+		return mathAtom{ ml: element("mi", ` mathvariant="normal"`, s) }, nil
+	}
+
+	if s, ok := texOperators[name]; ok {
+		return mathAtom{ ml: mo(s) }, nil
+	}
+
+	if op, ok := texLargeOperators[name]; ok {
+		return mathAtom{ ml: mo(op.symbol), limits: op.limits }, nil
+	}
+
+	if f, ok := texFunctions[name]; ok {
+		return mathAtom{ ml: element("mi", "", f.name), limits: f.limits }, nil
+	}
+
+	if w, ok := texSpaces[name]; ok {
+		return mathAtom{ ml: mspace(w) }, nil
+	}
+
+	if v, ok := texFonts[name]; ok {
+		previous := c.variant
+		c.variant = v
+
+		arg, err := c.parseArg(`\` + name)
+		c.variant = previous
+
+		return mathAtom{ ml: arg }, err
+	}
+
+	if accent, ok := texAccents[name]; ok {
+		arg, err := c.parseArg(`\` + name)
+		if err != nil {
+			return mathAtom{}, err
+		}
+
+		if accent.under {
+			return mathAtom{ ml: element("munder", ` accentunder="true"`, arg + mo(accent.symbol)), limits: accent.limits }, nil
+		}
+
+		return mathAtom{ ml: element("mover", ` accent="true"`, arg + mo(accent.symbol)), limits: accent.limits }, nil
+	}
+
+	if size, ok := texDelimiterSizes[name]; ok {
+		delim, err := c.parseDelimiter(`\` + name)
+		if err != nil {
+			return mathAtom{}, err
+		}
+
+		return mathAtom{ ml: element("mo", ` minsize="` + size + `" maxsize="` + size + `"`, html.EscapeString(delim)) }, nil
+	}
+
+	switch name {
+	case "{", "}", "|", "%", "$", "&", "#", "_":
+		if name == "|" {
+			return mathAtom{ ml: mo("‖") }, nil
+		}
+
+		return mathAtom{ ml: mo(name) }, nil
+
+	case "displaystyle", "textstyle", "scriptstyle":
+		return mathAtom{}, nil
+
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num, err := c.parseArg(`\` + name)
+		if err != nil {
+			return mathAtom{}, err
+		}
+
+		den, err := c.parseArg(`\` + name)
+		if err != nil {
+			return mathAtom{}, err
+		}
+
+		frac := element("mfrac", "", num + den)
+
+		switch name {
+		case "dfrac", "cfrac":
+			frac = element("mstyle", ` displaystyle="true"`, frac)
+		case "tfrac":
+			frac = element("mstyle", ` displaystyle="false"`, frac)
+		}
+
+		return mathAtom{ ml: frac }, nil
+
+	case "binom":
+		top, err := c.parseArg(`\binom`)
+		if err != nil {
+			return mathAtom{}, err
+		}
+
+		bottom, err := c.parseArg(`\binom`)
+		if err != nil {
+			return mathAtom{}, err
+		}
+
+		return mathAtom{ ml: "<mrow>" + mo("(") + element("mfrac", ` linethickness="0"`, top + bottom) + mo(")") + "</mrow>" }, nil
+
+	case "sqrt":
+		return c.parseSqrt()
+
+	case "text", "textrm", "textnormal", "mbox", "textit", "textbf", "textsf", "texttt":
+		text, err := c.parseRawArg(`\` + name)
+		if err != nil {
+			return mathAtom{}, err
+		}
+
+		attrs := ""
+
+		switch name {
+		case "textit":
+			attrs = ` mathvariant="italic"`
+		case "textbf":
+			attrs = ` mathvariant="bold"`
+		case "textsf":
+			attrs = ` mathvariant="sans-serif"`
+		case "texttt":
+			attrs = ` mathvariant="monospace"`
+		}
+
+		return mathAtom{ ml: element("mtext", attrs, html.EscapeString(text)) }, nil
+
+	case "operatorname":
+		text, err := c.parseRawArg(`\operatorname`)
+		if err != nil {
+			return mathAtom{}, err
+		}
+
+		return mathAtom{ ml: element("mi", ` mathvariant="normal"`, html.EscapeString(strings.TrimSpace(text))) }, nil
+
+	case "not":
+		c.skipSpace()
+
+		atom, err := c.parseAtom(true)
+		if err != nil {
+			return mathAtom{}, err
+		}
+
+		if !strings.HasPrefix(atom.ml, "<mo>") {
+			return mathAtom{}, c.errorAt(start, `\not can only be used before a relation`)
+		}
+
+		return mathAtom{ ml: strings.TrimSuffix(atom.ml, "</mo>") + "̸</mo>" }, nil
+
+	case "pmod":
+		arg, err := c.parseArg(`\pmod`)
+		if err != nil {
+			return mathAtom{}, err
+		}
+
+		return mathAtom{ ml: "<mrow>" + mspace("1em") + mo("(") + element("mi", "", "mod") + mspace("0.333em") + arg + mo(")") + "</mrow>" }, nil
+
+	case "left":
+		return c.parseLeftRight(start)
+
+	case "begin":
+		return c.parseEnvironment(start)
+	}
+
+	return mathAtom{}, c.errorAt(start, `unsupported TeX command '\%v'`, name)
+}
+
+func (c *texConverter) parseSqrt() (mathAtom, error) {
+	c.skipSpace()
+
+	if c.peek() != '[' {
+		arg, err := c.parseArg(`\sqrt`)
+		if err != nil {
+			return mathAtom{}, err
+		}
+
+		return mathAtom{ ml: element("msqrt", "", arg) }, nil
+	}
+
+	// The index is converted on its own, up to the closing bracket.
+	start := c.pos
+	end := strings.IndexByte(c.src[c.pos:c.limit], ']')
+
+	if end < 0 {
+		return mathAtom{}, c.errorAt(start, "missing ']'")
+	}
+
+	limit := c.limit
+	c.limit = c.pos + end
+	c.pos++
+
+	items, err := c.parseList()
+	if err == nil && !c.eof() {
+		err = c.unexpected()
+	}
+
+	c.limit = limit
+
+	if err != nil {
+		return mathAtom{}, err
+	}
+
+	c.pos++
+
+	arg, err := c.parseArg(`\sqrt`)
+	if err != nil {
+		return mathAtom{}, err
+	}
+
+	return mathAtom{ ml: element("mroot", "", arg + mrow(items)) }, nil
+}
+
+// Reads a delimiter for \left, \right, \big and so on. Returns an empty
+// string for the null delimiter ".".
+func (c *texConverter) parseDelimiter(of string) (string, error) {
+	c.skipSpace()
+
+	start := c.pos
+
+	if c.eof() {
+		return "", c.errorAt(start, "missing delimiter for %v", of)
+	}
+
+	if c.peek() == '\\' {
+		name := c.commandName()
+
+		if d, ok := texDelimiters[name]; ok {
+			return d, nil
+		}
+
+		return "", c.errorAt(start, `unsupported delimiter '\%v' for %v`, name, of)
+	}
+
+	ch := c.peek()
+	c.pos++
+
+	switch ch {
+	case '.':
+		return "", nil
+	case '(', ')', '[', ']', '|', '/':
+		return string(ch), nil
+	case '<':
+		return "⟨", nil
+	case '>':
+		return "⟩", nil
+	}
+
+	return "", c.errorAt(start, "unsupported delimiter '%v' for %v", string(ch), of)
+}
+
+func fence(delim string) string {
+	if delim == "" {
+		return ""
+	}
+
+	return element("mo", ` fence="true" stretchy="true"`, html.EscapeString(delim))
+}
+
+func (c *texConverter) parseLeftRight(start int) (mathAtom, error) {
+	left, err := c.parseDelimiter(`\left`)
+	if err != nil {
+		return mathAtom{}, err
+	}
+
+	items, err := c.parseList()
+	if err != nil {
+		return mathAtom{}, err
+	}
+
+	if !c.hasCommand("right") {
+		if c.eof() {
+			return mathAtom{}, c.errorAt(start, `missing \right for \left`)
+		}
+
+		return mathAtom{}, c.unexpected()
+	}
+
+	c.pos += len(`\right`)
+
+	right, err := c.parseDelimiter(`\right`)
+	if err != nil {
+		return mathAtom{}, err
+	}
+
+	return mathAtom{ ml: "<mrow>" + fence(left) + strings.Join(items, "") + fence(right) + "</mrow>" }, nil
+}
+
+// Reads the braced name of an environment.
+func (c *texConverter) environmentName(of string) (string, error) {
+	name, err := c.parseRawArg(of)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(name), nil
+}
+
+func (c *texConverter) parseEnvironment(start int) (mathAtom, error) {
+	name, err := c.environmentName(`\begin`)
+	if err != nil {
+		return mathAtom{}, err
+	}
+
+	env, ok := texEnvironments[name]
+	if !ok {
+		return mathAtom{}, c.errorAt(start, "unsupported environment '%v'", name)
+	}
+
+	rows := make([]string, 0)
+	cells := make([]string, 0)
+
+	for {
+		items, err := c.parseList()
+		if err != nil {
+			return mathAtom{}, err
+		}
+
+		cells = append(cells, element("mtd", "", mrow(items)))
+
+		switch {
+		case c.peek() == '&':
+			c.pos++
+			continue
+
+		case c.hasPrefix(`\\`):
+			c.pos += 2
+			rows = append(rows, element("mtr", "", strings.Join(cells, "")))
+			cells = cells[:0]
+			continue
+
+		case c.hasCommand("end"):
+			endStart := c.pos
+			c.pos += len(`\end`)
+
+			endName, err := c.environmentName(`\end`)
+			if err != nil {
+				return mathAtom{}, err
+			}
+
+			if endName != name {
+				return mathAtom{}, c.errorAt(endStart, `\end{%v} does not match \begin{%v}`, endName, name)
+			}
+
+			// A trailing \\ does not start a new row.
+			if len(cells) > 1 || cells[0] != "<mtd><mrow></mrow></mtd>" {
+				rows = append(rows, element("mtr", "", strings.Join(cells, "")))
+			}
+
+			table := element("mtable", env.attrs, strings.Join(rows, ""))
+
+			if env.left == "" && env.right == "" {
+				return mathAtom{ ml: table }, nil
+			}
+
+			return mathAtom{ ml: "<mrow>" + fence(env.left) + table + fence(env.right) + "</mrow>" }, nil
+
+		case c.eof():
+			return mathAtom{}, c.errorAt(start, `missing \end{%v}`, name)
+		}
+
+		return mathAtom{}, c.unexpected()
+	}
+}
+
+// Maps a letter or digit to its Unicode mathematical alphanumeric symbol in
+// the given font.
+func mathAlphanumeric(variant string, r rune) (rune, bool) {
+	type font struct {
+		upper rune
+		lower rune
+		digit rune
+		exceptions map[rune]rune
+	}
+
+	fonts := map[string]font{
+		"bold": { upper: 0x1D400, lower: 0x1D41A, digit: 0x1D7CE },
+		"double-struck": { upper: 0x1D538, lower: 0x1D552, digit: 0x1D7D8, exceptions: map[rune]rune{
+			'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ',
+		}},
+		"script": { upper: 0x1D49C, lower: 0x1D4B6, exceptions: map[rune]rune{
+			'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ', 'R': 'ℛ',
+			'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ',
+		}},
+		"fraktur": { upper: 0x1D504, lower: 0x1D51E, exceptions: map[rune]rune{
+			'C': 'ℭ', 'H': 'ℌ', 'I': 'ℑ', 'R': 'ℜ', 'Z': 'ℨ',
+		}},
+		"sans-serif": { upper: 0x1D5A0, lower: 0x1D5BA, digit: 0x1D7E2 },
+		"monospace": { upper: 0x1D670, lower: 0x1D68A, digit: 0x1D7F6 },
+	}
+
+	f, ok := fonts[variant]
+	if !ok {
+		return r, false
+	}
+
+	if e, ok := f.exceptions[r]; ok {
+		return e, true
+	}
+
+	switch {
+	case r >= 'A' && r <= 'Z':
+		return f.upper + r - 'A', true
+	case r >= 'a' && r <= 'z':
+		return f.lower + r - 'a', true
+	case r >= '0' && r <= '9' && f.digit != 0:
+		return f.digit + r - '0', true
+	}
+
+	return r, false
+}
@@ -0,0 +1,161 @@
+package parse
+
+// Symbols and commands understood by texConverter.
+
+// Commands converted to an identifier (<mi>).
+var texIdentifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ", "sigma": "σ",
+	"varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ", "varphi": "φ",
+	"chi": "χ", "psi": "ψ", "omega": "ω",
+
+	"infty": "∞", "partial": "∂", "nabla": "∇", "emptyset": "∅",
+	"varnothing": "∅", "ell": "ℓ", "hbar": "ℏ", "hslash": "ℏ", "aleph": "ℵ",
+	"beth": "ℶ", "Re": "ℜ", "Im": "ℑ", "wp": "℘", "imath": "ı", "jmath": "ȷ",
+	"top": "⊤", "bot": "⊥", "angle": "∠", "triangle": "△", "prime": "′",
+}
+
+// Commands converted to an upright identifier.
+var texUprightIdentifiers = map[string]string{
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ",
+	"Omega": "Ω",
+}
+
+// Commands converted to an operator (<mo>).
+var texOperators = map[string]string{
+	"pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "⋅", "ast": "∗",
+	"star": "⋆", "circ": "∘", "bullet": "∙", "oplus": "⊕", "ominus": "⊖",
+	"otimes": "⊗", "oslash": "⊘", "odot": "⊙", "cup": "∪", "cap": "∩",
+	"sqcup": "⊔", "sqcap": "⊓", "uplus": "⊎", "setminus": "∖", "wedge": "∧",
+	"land": "∧", "vee": "∨", "lor": "∨", "neg": "¬", "lnot": "¬",
+	"dagger": "†", "ddagger": "‡", "bmod": "mod",
+
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"ll": "≪", "gg": "≫", "approx": "≈", "sim": "∼", "simeq": "≃",
+	"cong": "≅", "equiv": "≡", "propto": "∝", "prec": "≺", "succ": "≻",
+	"preceq": "⪯", "succeq": "⪰", "subset": "⊂", "supset": "⊃",
+	"subseteq": "⊆", "supseteq": "⊇", "in": "∈", "notin": "∉", "ni": "∋",
+	"perp": "⊥", "parallel": "∥", "mid": "∣", "vert": "|", "Vert": "‖",
+	"models": "⊨", "vdash": "⊢", "dashv": "⊣", "coloneqq": "≔",
+	"triangleq": "≜", "colon": ":",
+
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←",
+	"leftrightarrow": "↔", "Rightarrow": "⇒", "Leftarrow": "⇐",
+	"Leftrightarrow": "⇔", "implies": "⟹", "impliedby": "⟸", "iff": "⟺",
+	"mapsto": "↦", "longrightarrow": "⟶", "longleftarrow": "⟵",
+	"longmapsto": "⟼", "uparrow": "↑", "downarrow": "↓",
+
+	"forall": "∀", "exists": "∃", "nexists": "∄", "therefore": "∴",
+	"because": "∵", "ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮",
+	"ddots": "⋱", "langle": "⟨", "rangle": "⟩", "lfloor": "⌊",
+	"rfloor": "⌋", "lceil": "⌈", "rceil": "⌉", "backslash": "∖",
+	"lbrace": "{", "rbrace": "}",
+}
+
+// Operators that get larger in display mode.
+var texLargeOperators = map[string]struct {
+	symbol string
+	limits bool
+}{
+	"sum": { "∑", true }, "prod": { "∏", true }, "coprod": { "∐", true },
+	"bigcup": { "⋃", true }, "bigcap": { "⋂", true },
+	"bigoplus": { "⨁", true }, "bigotimes": { "⨂", true },
+	"bigvee": { "⋁", true }, "bigwedge": { "⋀", true },
+	"bigsqcup": { "⨆", true },
+	"int": { "∫", false }, "iint": { "∬", false }, "iiint": { "∭", false },
+	"oint": { "∮", false },
+}
+
+// Named functions, written upright.
+var texFunctions = map[string]struct {
+	name string
+	limits bool
+}{
+	"arccos": { "arccos", false }, "arcsin": { "arcsin", false },
+	"arctan": { "arctan", false }, "arg": { "arg", false },
+	"cos": { "cos", false }, "cosh": { "cosh", false }, "cot": { "cot", false },
+	"coth": { "coth", false }, "csc": { "csc", false }, "deg": { "deg", false },
+	"dim": { "dim", false }, "exp": { "exp", false }, "hom": { "hom", false },
+	"ker": { "ker", false }, "lg": { "lg", false }, "ln": { "ln", false },
+	"log": { "log", false }, "sec": { "sec", false }, "sin": { "sin", false },
+	"sinh": { "sinh", false }, "tan": { "tan", false }, "tanh": { "tanh", false },
+
+	"det": { "det", true }, "gcd": { "gcd", true }, "inf": { "inf", true },
+	"lim": { "lim", true }, "liminf": { "lim inf", true },
+	"limsup": { "lim sup", true }, "max": { "max", true },
+	"min": { "min", true }, "Pr": { "Pr", true }, "sup": { "sup", true },
+	"argmax": { "arg max", true }, "argmin": { "arg min", true },
+}
+
+// Spacing commands and their widths.
+var texSpaces = map[string]string{
+	",": "0.167em", "thinspace": "0.167em", ":": "0.222em", ">": "0.222em",
+	";": "0.278em", "!": "-0.167em", " ": "0.333em", "enspace": "0.5em",
+	"quad": "1em", "qquad": "2em",
+}
+
+// Font commands and the font they set.
+var texFonts = map[string]string{
+	"mathrm": "normal", "mathit": "", "mathbf": "bold", "boldsymbol": "bold",
+	"bm": "bold", "mathbb": "double-struck", "mathcal": "script",
+	"mathscr": "script", "mathfrak": "fraktur", "mathsf": "sans-serif",
+	"mathtt": "monospace",
+}
+
+// Accents placed over (or under) their argument.
+var texAccents = map[string]struct {
+	symbol string
+	under bool
+	limits bool
+}{
+	"hat": { "^", false, false }, "widehat": { "^", false, false },
+	"bar": { "¯", false, false }, "overline": { "‾", false, false },
+	"vec": { "→", false, false }, "overrightarrow": { "→", false, false },
+	"tilde": { "~", false, false }, "widetilde": { "~", false, false },
+	"dot": { "˙", false, false }, "ddot": { "¨", false, false },
+	"check": { "ˇ", false, false }, "breve": { "˘", false, false },
+	"acute": { "´", false, false }, "grave": { "`", false, false },
+	"underline": { "_", true, false },
+	"overbrace": { "⏞", false, true }, "underbrace": { "⏟", true, true },
+}
+
+// Delimiter commands for \left, \right and the sizing commands.
+var texDelimiters = map[string]string{
+	"{": "{", "}": "}", "|": "‖", "lbrace": "{", "rbrace": "}",
+	"langle": "⟨", "rangle": "⟩", "lvert": "|", "rvert": "|", "vert": "|",
+	"lVert": "‖", "rVert": "‖", "Vert": "‖", "lfloor": "⌊", "rfloor": "⌋",
+	"lceil": "⌈", "rceil": "⌉", "backslash": "\\", "uparrow": "↑",
+	"downarrow": "↓",
+}
+
+// Sizing commands for delimiters and the size they set.
+var texDelimiterSizes = map[string]string{
+	"big": "1.2em", "bigl": "1.2em", "bigr": "1.2em", "bigm": "1.2em",
+	"Big": "1.623em", "Bigl": "1.623em", "Bigr": "1.623em", "Bigm": "1.623em",
+	"bigg": "2.047em", "biggl": "2.047em", "biggr": "2.047em", "biggm": "2.047em",
+	"Bigg": "2.470em", "Biggl": "2.470em", "Biggr": "2.470em", "Biggm": "2.470em",
+}
+
+// Supported environments, with the delimiters around them and the
+// attributes of their table.
+var texEnvironments = map[string]struct {
+	left string
+	right string
+	attrs string
+}{
+	"matrix": { "", "", "" },
+	"smallmatrix": { "", "", "" },
+	"pmatrix": { "(", ")", "" },
+	"bmatrix": { "[", "]", "" },
+	"Bmatrix": { "{", "}", "" },
+	"vmatrix": { "|", "|", "" },
+	"Vmatrix": { "‖", "‖", "" },
+	"cases": { "{", "", ` columnalign="left left"` },
+	"aligned": { "", "", ` columnalign="right left" columnspacing="0em"` },
+	"align": { "", "", ` columnalign="right left" columnspacing="0em"` },
+	"align*": { "", "", ` columnalign="right left" columnspacing="0em"` },
+	"gathered": { "", "", "" },
+}
@@ -2,68 +2,286 @@ package parse
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"html/template"
+	"strings"
+	"sync"
 
 	"github.com/aghorui/burlough/blog"
 	"github.com/aghorui/burlough/util"
 
 	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	emoji "github.com/yuin/goldmark-emoji"
 	highlighting "github.com/yuin/goldmark-highlighting/v2"
-	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
 	"go.abhg.dev/goldmark/frontmatter"
 )
 
+// Collects the headings of a document in the order they appear.
+func collectHeadings(doc ast.Node, src []byte) []blog.Heading {
+	headings := make([]blog.Heading, 0)
 
-func ParseBlogFile(src []byte) (blog.BlogFileContents, bool, error) {
-	var dest bytes.Buffer
-	var parseResult blog.BlogFileContents
-	var noMetadata bool = false
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
 
-	// TODO put this into the context.
-	md := goldmark.New(
-		goldmark.WithExtensions(
-			&frontmatter.Extender{},
-			highlighting.NewHighlighting(
-				highlighting.WithStyle("tango"),
-				highlighting.WithFormatOptions(
-					chromahtml.WithLineNumbers(true),
-					chromahtml.LinkableLineNumbers(true, "ln_"),
-				),
-			),
-			extension.GFM,
+		h, ok := n.(*ast.Heading)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+
+		var id string
+
+		if v, ok := h.AttributeString("id"); ok {
+			if b, ok := v.([]byte); ok {
+				id = string(b)
+			}
+		}
+
+		headings = append(headings, blog.Heading{
+			Level: h.Level,
+			ID: id,
+			Title: string(h.Text(src)),
+		})
+
+		return ast.WalkSkipChildren, nil
+	})
+
+	return headings
+}
+
+var errorsKey = parser.NewContextKey()
+
+// Returns the line number of a byte offset in a source.
+func lineAt(src []byte, offset int) int {
+	return bytes.Count(src[:offset], []byte("\n")) + 1
+}
+
+// Records an error found by an extension while parsing, along with the line
+// of the source it is in.
+func recordError(pc parser.Context, line int, err error) {
+	errs, _ := pc.Get(errorsKey).([]error)
+	pc.Set(errorsKey, append(errs, fmt.Errorf("line %v: %w", line, err)))
+}
+
+// Returns the errors recorded while parsing.
+func parseErrors(pc parser.Context) error {
+	errs, _ := pc.Get(errorsKey).([]error)
+	return errors.Join(errs...)
+}
+
+// A reusable markdown parser for blog files.
+type Parser struct {
+	md goldmark.Markdown
+	classes bool // Whether code blocks are highlighted with CSS classes.
+}
+
+// Creates a parser with the given markdown parameters and shortcodes. The
+// built-in shortcodes are used if shortcodes is nil.
+func NewParser(params blog.MarkdownParams, shortcodes Shortcodes) (*Parser, error) {
+	if shortcodes == nil {
+		shortcodes = BuiltinShortcodes()
+	}
+
+	if params.HighlightStyle == "" {
+		params.HighlightStyle = blog.DefaultConfigFileParams().Markdown.HighlightStyle
+	}
+
+	if _, ok := styles.Registry[params.HighlightStyle]; !ok {
+		return nil, fmt.Errorf("Unknown highlight style '%v'", params.HighlightStyle)
+	}
+
+	if _, ok := styles.Registry[params.HighlightDarkStyle]; params.HighlightDarkStyle != "" && !ok {
+		return nil, fmt.Errorf("Unknown highlight style '%v'", params.HighlightDarkStyle)
+	}
+
+	extensions := []goldmark.Extender{
+		&frontmatter.Extender{},
+		highlighting.NewHighlighting(
+			highlighting.WithStyle(params.HighlightStyle),
+			highlighting.WithFormatOptions(formatterOptions(params)...),
 		),
-		goldmark.WithRendererOptions(
-			html.WithUnsafe(),
+		extension.GFM,
+		&shortcodeExtender{ shortcodes: shortcodes },
+		&wikiLinkExtender{},
+	}
+
+	if params.Footnotes {
+		extensions = append(extensions, extension.Footnote)
+	}
+
+	if params.DefinitionLists {
+		extensions = append(extensions, extension.DefinitionList)
+	}
+
+	if params.Typographer {
+		extensions = append(extensions, extension.Typographer)
+	}
+
+	if params.Emoji {
+		extensions = append(extensions, emoji.Emoji)
+	}
+
+	if params.Math {
+		extensions = append(extensions, &mathExtender{})
+	}
+
+	rendererOptions := []renderer.Option{}
+
+	if params.Unsafe {
+		rendererOptions = append(rendererOptions, html.WithUnsafe())
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
 		),
+		goldmark.WithRendererOptions(rendererOptions...),
 	)
 
-	pc := parser.NewContext()
+	return &Parser{ md: md, classes: params.HighlightClasses }, nil
+}
 
-	err := md.Convert(src, &dest, parser.WithContext(pc))
+// Parser with the default markdown parameters, created on first use.
+var defaultParser struct {
+	once sync.Once
+	p *Parser
+	err error
+}
 
+// Parses a blog file with the default markdown parameters.
+func ParseBlogFile(src []byte) (blog.BlogFileContents, bool, error) {
+	defaultParser.once.Do(func() {
+		defaultParser.p, defaultParser.err = NewParser(blog.DefaultConfigFileParams().Markdown, nil)
+	})
+
+	if defaultParser.err != nil {
+		return blog.BlogFileContents{}, false, util.Error(defaultParser.err)
+	}
+
+	return defaultParser.p.Parse(src)
+}
+
+// Parses a blog file. Returns the contents and whether the file had no
+// metadata. Links are left as they are.
+func (p *Parser) Parse(src []byte) (blog.BlogFileContents, bool, error) {
+	d, err := p.ParseDocument(src)
 	if err != nil {
-		util.Error(err)
-		return parseResult, noMetadata, err
+		return blog.BlogFileContents{}, false, err
 	}
 
-	metadata := frontmatter.Get(pc)
+	contents, err := d.Render(Resolvers{})
+
+	return contents, d.NoMetadata, err
+}
+
+// A parsed blog file that has not been rendered yet. Parsing every file
+// before rendering any allows links between them to be resolved.
+type Document struct {
+	Contents blog.BlogFileContents // Contents of the file, without the rendered HTML.
+	NoMetadata bool                // Whether the file had no metadata.
+
+	parser *Parser
+	src []byte
+	doc ast.Node
+	pc parser.Context
+}
+
+// Parses a blog file into a document, including its metadata.
+func (p *Parser) ParseDocument(src []byte) (*Document, error) {
+	d := &Document{ parser: p, src: src, pc: parser.NewContext() }
+
+	d.doc = p.md.Parser().Parse(text.NewReader(src), parser.WithContext(d.pc))
+
+	if err := parseErrors(d.pc); err != nil {
+		return d, err
+	}
+
+	d.Contents.Headings = collectHeadings(d.doc, src)
+	d.Contents.WordCount = len(strings.Fields(collectText(d.doc, src)))
+	d.Contents.ReadingTime = readingTime(d.Contents.WordCount)
+
+	metadata := frontmatter.Get(d.pc)
 
 	if metadata != nil {
-		if err := metadata.Decode(&parseResult); err != nil {
-			return parseResult, noMetadata, err
+		if err := metadata.Decode(&d.Contents); err != nil {
+			return d, err
 		}
 	} else {
-		noMetadata = true
+		d.NoMetadata = true
+	}
+
+	if d.Contents.Title == "" {
+		d.Contents.Title = "(No Title)"
 	}
 
-	parseResult.Content = template.HTML(dest.Bytes())
+	return d, nil
+}
+
+// Renders a document, rewriting links and the paths of local files with the
+// resolvers. A document can only be rendered once.
+func (d *Document) Render(r Resolvers) (blog.BlogFileContents, error) {
+	var dest bytes.Buffer
+
+	md := d.parser.md
+	src := d.src
+	doc := d.doc
+	parseResult := d.Contents
+
+	resolveLinks(doc, src, r, d.pc)
 
-	if parseResult.Title == "" {
-		parseResult.Title = "(No Title)"
+	if r.WikiLinks != nil {
+		resolveWikiLinks(doc, src, r.WikiLinks, d.pc)
 	}
 
-	return parseResult, noMetadata, nil
-}
\ No newline at end of file
+	if err := parseErrors(d.pc); err != nil {
+		return parseResult, err
+	}
+
+	parseResult.Links = collectLinks(doc, src)
+
+	// The separator is taken out so that it does not end up in the output.
+	more := findMoreSeparator(doc, src)
+
+	if more >= 0 {
+		doc.RemoveChild(doc, childAt(doc, more))
+	}
+
+	err := md.Renderer().Render(&dest, src, doc)
+
+	if err != nil {
+		util.Error(err)
+		return parseResult, err
+	}
+
+	if more >= 0 {
+		summary := splitSummary(doc, more)
+
+		var summaryDest bytes.Buffer
+
+		err := md.Renderer().Render(&summaryDest, src, summary)
+
+		if err != nil {
+			util.Error(err)
+			return parseResult, err
+		}
+
+		parseResult.Summary = template.HTML(d.parser.postProcess(summaryDest.Bytes()))
+		parseResult.Text = collectText(summary, src)
+	} else {
+		parseResult.Text = collectText(doc, src)
+	}
+
+	parseResult.Content = template.HTML(d.parser.postProcess(dest.Bytes()))
+
+	return parseResult, nil
+}
@@ -1,10 +1,15 @@
 package parse
 
 import (
+	"html/template"
+	"strings"
 	"testing"
+	"testing/fstest"
 
+	"github.com/aghorui/burlough/blog"
 	"github.com/aghorui/burlough/util"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParse(t *testing.T) {
@@ -19,4 +24,216 @@ func TestParse(t *testing.T) {
 		assert.True(t, noMetadata, "there shouldn't be metadata in no_metadata.md")
 		assert.NoError(t, err, "there shouldn't be any errors while parsing the file.")
 	}
-}
\ No newline at end of file
+
+	{
+		b, _, err := ParseBlogFile(util.GetTestFile("markdown/noindex_toml.md"));
+		assert.NoError(t, err, "there shouldn't be any errors while parsing noindex_toml.md")
+		assert.True(t, b.NoIndex, "noindex should be set in noindex_toml.md")
+	}
+
+	{
+		b, _, err := ParseBlogFile(util.GetTestFile("markdown/headings.md"));
+		assert.NoError(t, err, "there shouldn't be any errors while parsing headings.md")
+		assert.Equal(t, []blog.Heading{
+			{ Level: 1, ID: "first-section", Title: "First Section" },
+			{ Level: 2, ID: "a-subsection", Title: "A Subsection" },
+			{ Level: 1, ID: "second-section", Title: "Second Section" },
+		}, b.Headings, "headings should be collected with their anchor IDs")
+		assert.Contains(t, string(b.Content), `<h1 id="first-section">`, "headings should have anchor IDs")
+	}
+
+	{
+		b, _, err := ParseBlogFile(util.GetTestFile("markdown/more_separator.md"));
+		assert.NoError(t, err, "there shouldn't be any errors while parsing more_separator.md")
+		assert.Equal(t, "<p>This is the <em>summary</em> of the post.</p>\n", string(b.Summary), "the summary should be the content before the separator")
+		assert.Equal(t, "This is the summary of the post.", b.Text)
+		assert.NotContains(t, string(b.Content), "more", "the separator should not be in the content")
+		assert.Equal(t, 14, b.WordCount, "code blocks should not be counted")
+		assert.Equal(t, 1, b.ReadingTime)
+	}
+
+	{
+		b, _, err := ParseBlogFile(util.GetTestFile("markdown/standard_toml.md"));
+		assert.NoError(t, err)
+		assert.Empty(t, b.Summary, "there should be no summary without a separator")
+		assert.NotEmpty(t, b.Text, "the text of the whole content should be kept for excerpts")
+	}
+}
+
+func TestParser(t *testing.T) {
+	params := blog.DefaultConfigFileParams().Markdown
+
+	{
+		p, err := NewParser(params, nil)
+		require.NoError(t, err, "the default parameters should be valid")
+
+		b, _, err := p.Parse([]byte("<b>raw</b> :smile:"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(b.Content), "<b>raw</b>", "raw HTML should be kept with unsafe on")
+		assert.Contains(t, string(b.Content), ":smile:", "emoji should be off by default")
+	}
+
+	{
+		params := params
+		params.Unsafe = false
+		params.Emoji = true
+		params.Footnotes = true
+
+		p, err := NewParser(params, nil)
+		require.NoError(t, err)
+
+		b, _, err := p.Parse([]byte("<b>raw</b> :smile: note[^1]\n\n[^1]: A footnote.\n"))
+		assert.NoError(t, err)
+		assert.NotContains(t, string(b.Content), "<b>", "raw HTML should be left out with unsafe off")
+		assert.NotContains(t, string(b.Content), ":smile:", "emoji shortcodes should be replaced")
+		assert.Contains(t, string(b.Content), `class="footnotes"`, "footnotes should be rendered")
+	}
+
+	{
+		params := params
+		params.HighlightStyle = "no-such-style"
+
+		_, err := NewParser(params, nil)
+		assert.Error(t, err, "unknown highlight styles should be an error")
+	}
+
+	{
+		params := params
+		params.HighlightClasses = true
+
+		p, err := NewParser(params, nil)
+		require.NoError(t, err)
+
+		b, _, err := p.Parse([]byte("```go\nfunc main() {}\n```\n"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(b.Content), `class="chroma"`, "code blocks should use classes")
+		assert.NotContains(t, string(b.Content), "style=", "code blocks should not have inline styles")
+	}
+
+	{
+		p, err := NewParser(params, nil)
+		require.NoError(t, err)
+
+		b, _, err := p.Parse([]byte("Text.\n\n[a](<my post.html#x>)\n![b](cat.png)\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, []blog.Link{ { URL: "my%20post.html#x", Line: 3 }, { URL: "cat.png", Line: 4 } }, b.Links,
+			"links should be collected as they are rendered, with their lines")
+	}
+}
+
+func TestMath(t *testing.T) {
+	params := blog.DefaultConfigFileParams().Markdown
+	params.Math = true
+
+	p, err := NewParser(params, nil)
+	require.NoError(t, err)
+
+	{
+		b, _, err := ParseBlogFile(util.GetTestFile("markdown/math.md"))
+		require.NoError(t, err)
+		assert.NotContains(t, string(b.Content), "<math", "math should be off by default")
+	}
+
+	{
+		b, _, err := p.Parse(util.GetTestFile("markdown/math.md"))
+		require.NoError(t, err, "there shouldn't be any errors while parsing math.md")
+		assert.Contains(t, string(b.Content), `<math xmlns="http://www.w3.org/1998/Math/MathML" display="inline">`, "inline math should be rendered")
+		assert.Contains(t, string(b.Content), `display="block"`, "display math should be rendered")
+		assert.Contains(t, string(b.Content), "<munderover><mo>∑</mo>", "limits of sums should go above and below in display math")
+		assert.Contains(t, string(b.Content), "$5 or $10", "prices should not be taken for math")
+	}
+
+	{
+		_, _, err := p.Parse(util.GetTestFile("markdown/math_unsupported.md"))
+		assert.EqualError(t, err, `line 9: unsupported TeX command '\unknowncommand'`, "errors should point at the source line")
+	}
+
+	for tex, expected := range map[string]string{
+		`x_i^2`: "<msubsup><mi>x</mi><mi>i</mi><mn>2</mn></msubsup>",
+		`x^12`: "<mrow><msup><mi>x</mi><mn>1</mn></msup><mn>2</mn></mrow>",
+		`\sqrt[3]{x}`: "<mroot><mi>x</mi><mn>3</mn></mroot>",
+		`\mathbb{R}`: "<mi>ℝ</mi>",
+		`a < b`: "<mo>&lt;</mo>",
+		`\begin{cases} 1 & x \\ 0 & y \end{cases}`: "<mtable columnalign=\"left left\"><mtr><mtd><mn>1</mn></mtd><mtd><mi>x</mi></mtd></mtr>",
+	} {
+		mathML, err := TeXToMathML(tex, false)
+		assert.NoError(t, err, tex)
+		assert.Contains(t, mathML, expected, tex)
+	}
+
+	for _, tex := range []string{ `{x`, `x}`, `\left( x`, `\begin{pmatrix} x`, `x^`, `x^1^2`, `a & b` } {
+		_, err := TeXToMathML(tex, false)
+		assert.Error(t, err, tex)
+	}
+}
+
+func TestShortcodes(t *testing.T) {
+	{
+		b, _, err := ParseBlogFile(util.GetTestFile("markdown/shortcodes.md"))
+		require.NoError(t, err)
+
+		content := string(b.Content)
+		assert.Contains(t, content, `<img src="https://example.com/cat.png" alt="A cat"`, "positional and named arguments should be passed")
+		assert.Contains(t, content, `<figcaption>A &#34;good&#34; dog &gt; a cat</figcaption>`, "quoted arguments should be unquoted")
+		assert.Contains(t, content, `<p class="note_title">Careful</p>`)
+		assert.Contains(t, content, "<p>Some <em>emphasized</em> text.</p>", "the content of paired shortcodes should be markdown")
+		assert.Contains(t, content, "This is written as is: {{&lt; note &gt;}}", "escaped shortcodes should be written as is")
+	}
+
+	{
+		_, _, err := ParseBlogFile([]byte("Some text.\n\n{{< nosuchshortcode >}}\n"))
+		assert.EqualError(t, err, "line 3: unknown shortcode 'nosuchshortcode'", "errors should point at the source line")
+	}
+
+	{
+		shortcodes, err := LoadShortcodes(fstest.MapFS{
+			"shortcodes/greet.html": { Data: []byte(`<span class="greet">Hello {{.Get "name" 0}}</span>`) },
+		}, "shortcodes")
+		require.NoError(t, err)
+
+		p, err := NewParser(blog.DefaultConfigFileParams().Markdown, shortcodes)
+		require.NoError(t, err)
+
+		b, _, err := p.Parse([]byte(`Say {{< greet name="World" >}}.`))
+		assert.NoError(t, err)
+		assert.Equal(t, template.HTML(`<p>Say <span class="greet">Hello World</span>.</p>` + "\n"), b.Content)
+
+		b, _, err = p.Parse([]byte(`Say {{< greet name="a >}} b" >}}.`))
+		assert.NoError(t, err)
+		assert.Equal(t, template.HTML(`<p>Say <span class="greet">Hello a &gt;}} b</span>.</p>` + "\n"), b.Content, "quoted arguments may contain the end of a tag")
+	}
+
+	{
+		b, _, err := ParseBlogFile([]byte("{{< note >}}\n\nFirst.\n\n{{< note >}}\n\nSecond.\n\n{{< /note >}}\n"))
+		require.NoError(t, err)
+
+		content := string(b.Content)
+		assert.Equal(t, 2, strings.Count(content, `class="note"`))
+		assert.Contains(t, content, "<p>First.</p>\n<div class=\"note\">\n<p>Second.</p>\n</div>\n", "an unclosed shortcode should not be paired with the closing tag of a later one")
+		assert.NotContains(t, content, "<p>First.</p>\n<div class=\"note\">\n<p>Second.</p>\n</div>\n\n</div>", "shortcodes should not be nested")
+	}
+}
+
+func TestSyntaxCSS(t *testing.T) {
+	params := blog.DefaultConfigFileParams().Markdown
+	params.HighlightDarkStyle = "monokai"
+
+	css, err := SyntaxCSS(params)
+	assert.NoError(t, err)
+	assert.Contains(t, string(css), ".chroma", "the stylesheet should style code blocks")
+	assert.Contains(t, string(css), "@media (prefers-color-scheme: dark)", "the dark style should be in a media query")
+
+	params.HighlightDarkStyle = "no-such-style"
+
+	_, err = SyntaxCSS(params)
+	assert.Error(t, err, "unknown highlight styles should be an error")
+
+	params = blog.DefaultConfigFileParams().Markdown
+	defaultCSS, err := SyntaxCSS(params)
+	require.NoError(t, err)
+
+	params.HighlightStyle = ""
+	css, err = SyntaxCSS(params)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultCSS, css, "the default highlight style should be used when none is set")
+}
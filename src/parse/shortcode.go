@@ -0,0 +1,521 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aghorui/burlough/static"
+	"github.com/aghorui/burlough/util"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	gutil "github.com/yuin/goldmark/util"
+)
+
+// Shortcode templates by the name of the shortcode.
+type Shortcodes map[string]*template.Template
+
+// Directory of the built-in shortcodes in static.BuiltinShortcodes.
+const builtinShortcodeDirectory = "shortcodes"
+
+// Loads every "<name>.html" file in a directory of a filesystem as the
+// shortcode <name>.
+func LoadShortcodes(folder fs.FS, dir string) (Shortcodes, error) {
+	shortcodes := make(Shortcodes)
+
+	files, err := fs.Glob(folder, path.Join(dir, "*.html"))
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".html")
+
+		t, err := template.New(path.Base(file)).ParseFS(folder, file)
+		if err != nil {
+			return nil, fmt.Errorf("Error encountered while loading shortcode %v: %w", name, err)
+		}
+
+		shortcodes[name] = t
+	}
+
+	return shortcodes, nil
+}
+
+// Returns the built-in shortcodes: figure, youtube, note and details.
+func BuiltinShortcodes() Shortcodes {
+	shortcodes, err := LoadShortcodes(static.BuiltinShortcodes, builtinShortcodeDirectory)
+	if err != nil {
+		panic(err)
+	}
+
+	return shortcodes
+}
+
+// Data given to shortcode templates.
+type ShortcodeContext struct {
+	Name string
+	Params map[string]string // Named arguments (key="value")
+	Args []string            // Positional arguments
+	Inner template.HTML      // Rendered content between the opening and closing tag
+
+	resources LinkResolver
+}
+
+// Returns the URL of a local file used by the shortcode, such as an image, and
+// copies the file along with the post. Other URLs are returned as they are.
+func (c ShortcodeContext) Resource(target string) (string, error) {
+	path, u := localLinkTarget(target)
+	if path == "" || c.resources == nil {
+		return target, nil
+	}
+
+	resolved, err := c.resources(path)
+	if err != nil {
+		return "", err
+	}
+
+	u.Path = ""
+	u.RawPath = ""
+
+	return resolved + u.String(), nil
+}
+
+// Returns the named argument key, or the positional argument at position if
+// it is not set.
+func (c ShortcodeContext) Get(key string, position int) string {
+	if v, ok := c.Params[key]; ok {
+		return v
+	}
+
+	if position >= 0 && position < len(c.Args) {
+		return c.Args[position]
+	}
+
+	return ""
+}
+
+// Kind of Shortcode nodes.
+var KindShortcode = ast.NewNodeKind("Shortcode")
+
+// Kind of ShortcodeBlock nodes.
+var KindShortcodeBlock = ast.NewNodeKind("ShortcodeBlock")
+
+// A shortcode tag.
+type shortcodeTag struct {
+	name string
+	closing bool
+	params map[string]string
+	args []string
+}
+
+// A shortcode within a paragraph.
+type Shortcode struct {
+	ast.BaseInline
+	shortcodeTag
+	line int
+	resources LinkResolver
+}
+
+func (n *Shortcode) Kind() ast.NodeKind {
+	return KindShortcode
+}
+
+func (n *Shortcode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{ "Name": n.name }, nil)
+}
+
+// A shortcode on its own line. Paired shortcodes contain the blocks between
+// their opening and closing tags.
+type ShortcodeBlock struct {
+	ast.BaseBlock
+	shortcodeTag
+	line int
+	paired bool
+	closed bool
+	resources LinkResolver
+	suffix string // Output after the content, set while rendering.
+}
+
+func (n *ShortcodeBlock) Kind() ast.NodeKind {
+	return KindShortcodeBlock
+}
+
+func (n *ShortcodeBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{ "Name": n.name }, nil)
+}
+
+var shortcodeNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+`)
+var shortcodeArgRegex = regexp.MustCompile(`^(?:([A-Za-z0-9_-]+)=)?("(?:[^"\\]|\\.)*"|'[^']*'|[^\s"']+)`)
+
+// Returns the index of the ">}}" that ends the shortcode tag at the start of
+// s, or -1 if there is none. Quoted arguments are skipped the same way
+// shortcodeArgRegex tokenises them, so they may contain ">}}". If a quote is
+// left open, the first ">}}" is used so that the arguments are reported as
+// malformed.
+func shortcodeTagEnd(s []byte) int {
+	var quote byte
+
+	for i := len("{{<"); i < len(s); i++ {
+		switch c := s[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case bytes.HasPrefix(s[i:], []byte(">}}")):
+			return i
+		}
+	}
+
+	return bytes.Index(s, []byte(">}}"))
+}
+
+// Parses a shortcode tag at the start of s. Returns the tag and its length,
+// or a length of 0 if s does not start with a shortcode tag.
+func parseShortcodeTag(s []byte) (shortcodeTag, int, error) {
+	if !bytes.HasPrefix(s, []byte("{{<")) {
+		return shortcodeTag{}, 0, nil
+	}
+
+	end := shortcodeTagEnd(s)
+	if end < 0 {
+		return shortcodeTag{}, 0, nil
+	}
+
+	length := end + len(">}}")
+	inner := strings.TrimSpace(string(s[len("{{<"):end]))
+	inner = strings.TrimSpace(strings.TrimSuffix(inner, "/"))
+
+	tag := shortcodeTag{
+		closing: strings.HasPrefix(inner, "/"),
+		params: make(map[string]string),
+	}
+
+	inner = strings.TrimSpace(strings.TrimPrefix(inner, "/"))
+
+	tag.name = shortcodeNameRegex.FindString(inner)
+	if tag.name == "" {
+		return shortcodeTag{}, 0, nil
+	}
+
+	rest := strings.TrimSpace(inner[len(tag.name):])
+
+	for rest != "" {
+		am := shortcodeArgRegex.FindStringSubmatch(rest)
+		if am == nil {
+			return tag, length, fmt.Errorf("malformed arguments for shortcode '%v': %v", tag.name, rest)
+		}
+
+		value := am[2]
+
+		switch value[0] {
+		case '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return tag, length, fmt.Errorf("malformed arguments for shortcode '%v': %v", tag.name, value)
+			}
+
+			value = unquoted
+		case '\'':
+			value = value[1:len(value) - 1]
+		}
+
+		if am[1] != "" {
+			tag.params[am[1]] = value
+		} else {
+			tag.args = append(tag.args, value)
+		}
+
+		rest = strings.TrimSpace(rest[len(am[0]):])
+	}
+
+	if tag.closing && (len(tag.params) > 0 || len(tag.args) > 0) {
+		return tag, length, fmt.Errorf("closing shortcode '%v' cannot have arguments", tag.name)
+	}
+
+	return tag, length, nil
+}
+
+// Whether a line consists only of the closing tag of a shortcode.
+func isShortcodeClosingLine(line []byte, name string) bool {
+	trimmed := bytes.TrimSpace(line)
+
+	tag, length, err := parseShortcodeTag(trimmed)
+
+	return err == nil && length == len(trimmed) && tag.closing && tag.name == name
+}
+
+// Whether a line contains an opening tag of a shortcode.
+func hasShortcodeOpeningTag(line []byte, name string) bool {
+	for i := bytes.Index(line, []byte("{{<")); i >= 0; {
+		tag, length, _ := parseShortcodeTag(line[i:])
+		if length > 0 && !tag.closing && tag.name == name {
+			return true
+		}
+
+		next := bytes.Index(line[i + 1:], []byte("{{<"))
+		if next < 0 {
+			break
+		}
+
+		i += next + 1
+	}
+
+	return false
+}
+
+// Whether a closing tag for a shortcode follows in the source, before the
+// shortcode is opened again.
+func hasShortcodeClosingTag(src []byte, name string) bool {
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		if isShortcodeClosingLine(line, name) {
+			return true
+		}
+
+		if hasShortcodeOpeningTag(line, name) {
+			return false
+		}
+	}
+
+	return false
+}
+
+type shortcodeInlineParser struct {
+	shortcodes Shortcodes
+}
+
+func (p *shortcodeInlineParser) Trigger() []byte {
+	return []byte{ '{' }
+}
+
+func (p *shortcodeInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+
+	// {{</* name */>}} is written out as {{< name >}}.
+	if bytes.HasPrefix(line, []byte("{{</*")) {
+		end := bytes.Index(line, []byte("*/>}}"))
+		if end < 0 {
+			return nil
+		}
+
+		block.Advance(end + len("*/>}}"))
+
+		return ast.NewString([]byte("{{<" + string(line[len("{{</*"):end]) + ">}}"))
+	}
+
+	tag, length, err := parseShortcodeTag(line)
+	if length == 0 {
+		return nil
+	}
+
+	lineNumber := lineAt(block.Source(), segment.Start)
+	block.Advance(length)
+
+	if err != nil {
+		recordError(pc, lineNumber, err)
+	} else if tag.closing {
+		recordError(pc, lineNumber, fmt.Errorf("closing shortcode '%v' without an opening one on its own line", tag.name))
+	} else if _, ok := p.shortcodes[tag.name]; !ok {
+		recordError(pc, lineNumber, fmt.Errorf("unknown shortcode '%v'", tag.name))
+	}
+
+	return &Shortcode{ shortcodeTag: tag, line: lineNumber }
+}
+
+type shortcodeBlockParser struct {
+	shortcodes Shortcodes
+}
+
+func (p *shortcodeBlockParser) Trigger() []byte {
+	return []byte{ '{' }
+}
+
+func (p *shortcodeBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+
+	if pos < 0 || bytes.HasPrefix(line[pos:], []byte("{{</*")) {
+		return nil, parser.NoChildren
+	}
+
+	trimmed := bytes.TrimSpace(line[pos:])
+
+	tag, length, err := parseShortcodeTag(trimmed)
+
+	// Shortcodes sharing a line with other content are inline shortcodes.
+	if length == 0 || length != len(trimmed) {
+		return nil, parser.NoChildren
+	}
+
+	lineNumber := lineAt(reader.Source(), segment.Start)
+	reader.Advance(segment.Len() - 1)
+
+	node := &ShortcodeBlock{ shortcodeTag: tag, line: lineNumber }
+
+	if err != nil {
+		recordError(pc, lineNumber, err)
+		return node, parser.NoChildren
+	}
+
+	if tag.closing {
+		recordError(pc, lineNumber, fmt.Errorf("closing shortcode '%v' without an opening one", tag.name))
+		return node, parser.NoChildren
+	}
+
+	if _, ok := p.shortcodes[tag.name]; !ok {
+		recordError(pc, lineNumber, fmt.Errorf("unknown shortcode '%v'", tag.name))
+	}
+
+	if hasShortcodeClosingTag(reader.Source()[segment.Stop:], tag.name) {
+		node.paired = true
+		return node, parser.HasChildren
+	}
+
+	return node, parser.NoChildren
+}
+
+func (p *shortcodeBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*ShortcodeBlock)
+
+	if !n.paired || n.closed {
+		return parser.Close
+	}
+
+	line, segment := reader.PeekLine()
+	if line == nil {
+		return parser.Close
+	}
+
+	if isShortcodeClosingLine(line, n.name) {
+		n.closed = true
+		reader.Advance(segment.Len() - 1)
+		return parser.Close
+	}
+
+	return parser.Continue | parser.HasChildren
+}
+
+func (p *shortcodeBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *shortcodeBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (p *shortcodeBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// Marks the place of the content in the output of paired shortcodes.
+const shortcodeInnerMarker = "\x00shortcode-inner\x00"
+
+type shortcodeRenderer struct {
+	shortcodes Shortcodes
+}
+
+func (r *shortcodeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindShortcode, r.renderShortcode)
+	reg.Register(KindShortcodeBlock, r.renderShortcodeBlock)
+}
+
+// Executes the template of a shortcode.
+func (r *shortcodeRenderer) execute(tag shortcodeTag, line int, inner template.HTML, resources LinkResolver) (string, error) {
+	t, ok := r.shortcodes[tag.name]
+	if !ok || tag.closing {
+		// Already recorded while parsing.
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+
+	err := t.Execute(&buf, ShortcodeContext{
+		Name: tag.name,
+		Params: tag.params,
+		Args: tag.args,
+		Inner: inner,
+		resources: resources,
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("line %v: Error encountered while expanding shortcode '%v': %w", line, tag.name, err)
+	}
+
+	return buf.String(), nil
+}
+
+func (r *shortcodeRenderer) renderShortcode(w gutil.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*Shortcode)
+
+	out, err := r.execute(n.shortcodeTag, n.line, "", n.resources)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
+	_, _ = w.WriteString(out)
+
+	return ast.WalkContinue, nil
+}
+
+func (r *shortcodeRenderer) renderShortcodeBlock(w gutil.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ShortcodeBlock)
+
+	if !entering {
+		_, _ = w.WriteString(n.suffix)
+		return ast.WalkContinue, nil
+	}
+
+	out, err := r.execute(n.shortcodeTag, n.line, shortcodeInnerMarker, n.resources)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
+	prefix, suffix, found := strings.Cut(out, shortcodeInnerMarker)
+
+	if strings.Contains(suffix, shortcodeInnerMarker) {
+		return ast.WalkStop, fmt.Errorf("line %v: shortcode '%v' can only use .Inner once", n.line, n.name)
+	}
+
+	_, _ = w.WriteString(prefix)
+
+	if !found {
+		// The content is not used by the shortcode.
+		n.suffix = "\n"
+		return ast.WalkSkipChildren, nil
+	}
+
+	n.suffix = suffix + "\n"
+	_, _ = w.WriteString("\n")
+
+	return ast.WalkContinue, nil
+}
+
+// Extension for shortcodes.
+type shortcodeExtender struct {
+	shortcodes Shortcodes
+}
+
+func (e *shortcodeExtender) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(gutil.Prioritized(&shortcodeBlockParser{ shortcodes: e.shortcodes }, 760)),
+		parser.WithInlineParsers(gutil.Prioritized(&shortcodeInlineParser{ shortcodes: e.shortcodes }, 510)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(gutil.Prioritized(&shortcodeRenderer{ shortcodes: e.shortcodes }, 500)),
+	)
+}
@@ -0,0 +1,111 @@
+package parse
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Separator between the summary of a blog file and the rest of it.
+const MoreSeparator = "<!--more-->"
+
+// Reading speed used for estimating reading times.
+const WordsPerMinute = 200
+
+// Returns the index of the top level block that consists only of the
+// <!--more--> separator, or -1 if there is none.
+func findMoreSeparator(doc ast.Node, src []byte) int {
+	index := 0
+
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if block, ok := n.(*ast.HTMLBlock); ok {
+			var value bytes.Buffer
+
+			lines := block.Lines()
+
+			for i := 0; i < lines.Len(); i++ {
+				line := lines.At(i)
+				value.Write(line.Value(src))
+			}
+
+			if block.HasClosure() {
+				value.Write(block.ClosureLine.Value(src))
+			}
+
+			// Also accept spaced out forms such as "<!-- more -->".
+			if strings.ReplaceAll(strings.TrimSpace(value.String()), " ", "") == MoreSeparator {
+				return index
+			}
+		}
+
+		index++
+	}
+
+	return -1
+}
+
+// Returns the child of a node at the given index.
+func childAt(n ast.Node, index int) ast.Node {
+	child := n.FirstChild()
+
+	for i := 0; i < index && child != nil; i++ {
+		child = child.NextSibling()
+	}
+
+	return child
+}
+
+// Moves the first count children of doc into a new document and returns it.
+func splitSummary(doc ast.Node, count int) ast.Node {
+	summary := ast.NewDocument()
+
+	for i := 0; i < count && doc.FirstChild() != nil; i++ {
+		child := doc.FirstChild()
+		doc.RemoveChild(doc, child)
+		summary.AppendChild(summary, child)
+	}
+
+	return summary
+}
+
+// Returns the plain text of a document. Code blocks and raw HTML are left out.
+func collectText(doc ast.Node, src []byte) string {
+	var b strings.Builder
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if n.Type() == ast.TypeBlock {
+				b.WriteByte(' ')
+			}
+
+			return ast.WalkContinue, nil
+		}
+
+		switch n := n.(type) {
+		case *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			b.Write(n.Segment.Value(src))
+
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(n.Value)
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Returns the estimated reading time in minutes for a number of words.
+func readingTime(wordCount int) int {
+	if wordCount <= 0 {
+		return 0
+	}
+
+	return (wordCount + WordsPerMinute - 1) / WordsPerMinute
+}
@@ -0,0 +1,137 @@
+package parse
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	gutil "github.com/yuin/goldmark/util"
+)
+
+// Kind of WikiLink nodes.
+var KindWikiLink = ast.NewNodeKind("WikiLink")
+
+// A link written as [[target]] or [[target|label]]. Its children are the
+// label.
+type WikiLink struct {
+	ast.BaseInline
+	Target string
+	Destination []byte // Empty until resolved. Unresolved links are written as text.
+}
+
+func (n *WikiLink) Kind() ast.NodeKind {
+	return KindWikiLink
+}
+
+func (n *WikiLink) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{ "Target": n.Target }, nil)
+}
+
+type wikiLinkParser struct{}
+
+func (p *wikiLinkParser) Trigger() []byte {
+	return []byte{ '[' }
+}
+
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+
+	if !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+
+	end := bytes.Index(line, []byte("]]"))
+	if end < 0 {
+		return nil
+	}
+
+	inner := line[2:end]
+	if bytes.ContainsAny(inner, "[]\n") {
+		return nil
+	}
+
+	target := inner
+	labelStart := segment.Start + 2
+	labelStop := segment.Start + end
+
+	if bar := bytes.IndexByte(inner, '|'); bar >= 0 {
+		target = inner[:bar]
+		labelStart += bar + 1
+	}
+
+	target = bytes.TrimSpace(target)
+	if len(target) == 0 {
+		return nil
+	}
+
+	block.Advance(end + 2)
+
+	label := text.NewSegment(labelStart, labelStop)
+	label = label.TrimLeftSpace(block.Source())
+	label = label.TrimRightSpace(block.Source())
+
+	node := &WikiLink{ Target: string(target) }
+	node.AppendChild(node, ast.NewTextSegment(label))
+
+	return node
+}
+
+// Resolves the targets of wiki links with a resolver. Errors from the
+// resolver are recorded with the line of the link.
+func resolveWikiLinks(doc ast.Node, src []byte, links LinkResolver, pc parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		link, ok := n.(*WikiLink)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		resolved, err := links(link.Target)
+		if err != nil {
+			recordError(pc, nodeLine(link, src), err)
+			return ast.WalkSkipChildren, nil
+		}
+
+		link.Destination = []byte(resolved)
+
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+type wikiLinkRenderer struct{}
+
+func (r *wikiLinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindWikiLink, r.renderWikiLink)
+}
+
+func (r *wikiLinkRenderer) renderWikiLink(w gutil.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*WikiLink)
+
+	if len(n.Destination) == 0 {
+		return ast.WalkContinue, nil
+	}
+
+	if entering {
+		_, _ = w.WriteString(`<a class="wikilink" href="`)
+		_, _ = w.Write(gutil.EscapeHTML(gutil.URLEscape(n.Destination, true)))
+		_, _ = w.WriteString(`">`)
+	} else {
+		_, _ = w.WriteString("</a>")
+	}
+
+	return ast.WalkContinue, nil
+}
+
+// Extension for [[wiki links]].
+type wikiLinkExtender struct{}
+
+func (e *wikiLinkExtender) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithInlineParsers(gutil.Prioritized(&wikiLinkParser{}, 199)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(gutil.Prioritized(&wikiLinkRenderer{}, 500)),
+	)
+}
@@ -10,7 +10,6 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
-	"strings"
 	"time"
 
 	"github.com/aghorui/burlough/blog"
@@ -22,6 +21,7 @@ import (
 
 const ProjectConfigFileName = "burlough.json"
 const DefaultBlogFileExtension = ".md"
+const BundleIndexFileName = "index.md"
 const DefaultWhitespaceReplacement = "-"
 const hashingBufferSize = 2048
 
@@ -62,38 +62,103 @@ func getHash(file *os.File) (blog.FileHash, error) {
 	return blog.FileHash(hex.EncodeToString(hasher.Sum(nil))), nil
 }
 
+// Get the hash of every file in a page bundle directory, including their paths
+// within it.
+func getBundleHash(dir string) (blog.FileHash, error) {
+	hasher := sha1.New()
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		fh, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+
+		defer fh.Close()
+
+		filehash, err := getHash(fh)
+		if err != nil {
+			return err
+		}
+
+		hasher.Write([]byte(filepath.ToSlash(rel) + "\x00" + string(filehash) + "\n"))
+
+		return nil
+	})
+
+	if err != nil {
+		return blog.FileHash(""), util.Error(err)
+	}
+
+	return blog.FileHash(hex.EncodeToString(hasher.Sum(nil))), nil
+}
+
 // Type used for making a dictionary between blog filename -> Blog Metadata.
 // We still want the slice to be preserved.
 type MetadataMap map[string]int
 
-// Scans all blog files (*.md) within a folder and returns metadata for them.
+// Scans all blog files (*.md) and page bundles (directories with an index.md)
+// within a folder and returns metadata for them.
 func scanBlogFiles(files []fs.DirEntry, useFileTimestampAsCreationDate bool) ([]blog.BlogMetadata, MetadataMap, error) {
 	projectFiles := make([]blog.BlogMetadata, 0, 10)
 	metaMap := make(MetadataMap)
 
 	for _, file := range files {
+		var filePath string
+		var filehash blog.FileHash
+		var bundle bool
+
 		if file.IsDir() {
-			continue
-		}
+			filePath = filepath.Join(file.Name(), BundleIndexFileName)
 
-		if filepath.Ext(file.Name()) != DefaultBlogFileExtension {
-			continue
-		}
+			if _, err := os.Stat(filePath); err != nil {
+				continue
+			}
 
-		fh, err := os.Open(file.Name())
-		if err != nil {
-			return nil, nil, util.Error(err)
-		}
+			h, err := getBundleHash(file.Name())
+			if err != nil {
+				return nil, nil, util.Error(err)
+			}
 
-		filehash, err := getHash(fh)
-		if err != nil {
-			return nil, nil, util.Error(err)
+			filehash = h
+			bundle = true
+		} else {
+			if filepath.Ext(file.Name()) != DefaultBlogFileExtension {
+				continue
+			}
+
+			fh, err := os.Open(file.Name())
+			if err != nil {
+				return nil, nil, util.Error(err)
+			}
+
+			h, err := getHash(fh)
+			fh.Close()
+
+			if err != nil {
+				return nil, nil, util.Error(err)
+			}
+
+			filePath = file.Name()
+			filehash = h
 		}
 
 		var createdTime time.Time
 
 		if useFileTimestampAsCreationDate {
-			info, err := file.Info()
+			info, err := os.Stat(filePath)
 
 			if err != nil {
 				return nil, nil, util.Error(err)
@@ -105,13 +170,14 @@ func scanBlogFiles(files []fs.DirEntry, useFileTimestampAsCreationDate bool) ([]
 		}
 
 		projectFiles = append(projectFiles, blog.BlogMetadata{
-			Path: file.Name(),
+			Path: filePath,
 			Hash: filehash,
 			Updated: time.Time{},
 			Created: createdTime,
+			Bundle: bundle,
 		})
 
-		metaMap[file.Name()] = len(projectFiles) - 1
+		metaMap[filePath] = len(projectFiles) - 1
 	}
 
 	return projectFiles, metaMap, nil
@@ -306,7 +372,9 @@ func Load(basePath string) (ProjectState, error) {
 		}
 	}
 
-	var params blog.ConfigFileParams
+	// Start from the defaults so that values missing from older config files
+	// are filled in.
+	params := blog.DefaultConfigFileParams()
 	err = json.Unmarshal(data, &params);
 
 	if err != nil {
@@ -408,8 +476,7 @@ func (state ProjectState) WriteConfig() error {
 }
 
 func sanitizeString(s string, prefixTime bool) string {
-	sl := strings.Join(strings.Fields(s), DefaultWhitespaceReplacement)
-	sanitized := strings.ToLower(string(util.SanitizeRegex.ReplaceAll([]byte(sl), []byte(""))))
+	sanitized := util.Slugify(s)
 
 	if prefixTime {
 		timePrefix := time.Now().Format("20060102")
@@ -503,4 +570,53 @@ func (state ProjectState) Render(renderOverride string) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// Returns the links between the tracked files of the project.
+func (state ProjectState) LinkGraph() (render.Graph, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		util.LogErr(err)
+		panic(err)
+	}
+
+	// Switch back to wd after we are done
+	defer func(wd string) {
+		if err := os.Chdir(wd); err != nil {
+			util.LogErr(err)
+			panic(err)
+		}
+	}(wd)
+
+	// Chdir to project base
+	if err := os.Chdir(state.BasePath); err != nil {
+		return render.Graph{}, util.Error(err)
+	}
+
+	return render.BuildGraph(state.BasePath, &state.Template, state.ConfigFileParams)
+}
+
+// Returns the links in the tracked files of the project, by the paths of the
+// pages they are rendered to.
+func (state ProjectState) PageLinks() (map[string][]blog.Link, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		util.LogErr(err)
+		panic(err)
+	}
+
+	// Switch back to wd after we are done
+	defer func(wd string) {
+		if err := os.Chdir(wd); err != nil {
+			util.LogErr(err)
+			panic(err)
+		}
+	}(wd)
+
+	// Chdir to project base
+	if err := os.Chdir(state.BasePath); err != nil {
+		return nil, util.Error(err)
+	}
+
+	return render.PageLinks(state.BasePath, &state.Template, state.ConfigFileParams)
+}
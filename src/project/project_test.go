@@ -1,6 +1,7 @@
 package project
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -45,5 +46,57 @@ func TestProject(t *testing.T) {
 		err = state.Render(outDir)
 
 		assert.Nil(t, err, "there shouldn't be any errors during project render")
+		assert.FileExists(t, filepath.Join(outDir, "tags", "index.html"), "tag overview page should be generated")
+		assert.FileExists(t, filepath.Join(outDir, "tags", "these.html"), "tag pages should be generated")
+		assert.FileExists(t, filepath.Join(outDir, "archive", "index.html"), "archive overview page should be generated")
+
+		state.BaseURL = "https://example.com/blog"
+		state.Feed.RSS = true
+		state.Feed.Atom = true
+		state.Feed.JSON = true
+		state.Sitemap = true
+		state.Graph = true
+
+		err = state.Render(outDir)
+
+		assert.Nil(t, err, "there shouldn't be any errors during project render with feeds")
+		assert.FileExists(t, filepath.Join(outDir, "feed.xml"), "RSS feed should be generated")
+		assert.FileExists(t, filepath.Join(outDir, "atom.xml"), "Atom feed should be generated")
+		assert.FileExists(t, filepath.Join(outDir, "feed.json"), "JSON feed should be generated")
+		assert.FileExists(t, filepath.Join(outDir, "sitemap.xml"), "sitemap should be generated")
+		assert.NoFileExists(t, filepath.Join(outDir, "robots.txt"), "robots.txt should not be generated for blogs under a path")
+		assert.FileExists(t, filepath.Join(outDir, "graph.json"), "link graph should be generated")
 	}
-}
\ No newline at end of file
+}
+
+func TestBundle(t *testing.T) {
+	dir := t.TempDir()
+
+	var b blog.ConfigFileParams
+	b.RenderPath = t.TempDir()
+
+	bundle := filepath.Join(dir, "trip")
+	assert.NoError(t, os.MkdirAll(bundle, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(bundle, "index.md"), []byte("+++\ntitle = \"Trip\"\n+++\n\n[Route](route.gpx)\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(bundle, "route.gpx"), []byte("one"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "not_a_bundle"), 0755))
+
+	state, _, err := Init(dir, b, true)
+	assert.NoError(t, err)
+	assert.Len(t, state.Files, 1, "only directories with an index.md should be tracked")
+	assert.Equal(t, filepath.Join("trip", "index.md"), state.Files[0].Path)
+	assert.True(t, state.Files[0].Bundle)
+
+	hash := state.Files[0].Hash
+
+	assert.NoError(t, os.WriteFile(filepath.Join(bundle, "route.gpx"), []byte("two"), 0644))
+
+	log, err := state.Scan()
+	assert.NoError(t, err)
+	assert.Equal(t, []UpdateLog{ { Updated, filepath.Join("trip", "index.md") } }, log, "changing any file in a bundle should update it")
+	assert.NotEqual(t, hash, state.Files[0].Hash)
+
+	assert.NoError(t, state.Render(b.RenderPath))
+	assert.FileExists(t, filepath.Join(b.RenderPath, "trip", "index.html"))
+	assert.FileExists(t, filepath.Join(b.RenderPath, "trip", "route.gpx"), "bundle files should be copied next to the page")
+}
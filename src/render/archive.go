@@ -0,0 +1,128 @@
+package render
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/aghorui/burlough/blogtemplate"
+)
+
+// Directory that archive pages are rendered into.
+const ArchiveDirectory = "archive"
+
+// Entries created in a given month.
+type ArchiveMonth struct {
+	Year int
+	Month int            // 1 to 12
+	MonthName string     // "January", "February", ...
+	URL string           // Path of the month page, relative to the root of the blog.
+	Entries []blogtemplate.BlogTemplateEntry
+}
+
+// Entries created in a given year, grouped by month.
+type ArchiveYear struct {
+	Year int
+	URL string           // Path of the year page, relative to the root of the blog.
+	Count int            // Number of entries in the year.
+	Months []ArchiveMonth
+}
+
+func archiveYearPath(year int) string {
+	return path.Join(ArchiveDirectory, strconv.Itoa(year), "index.html")
+}
+
+func archiveMonthPath(year int, month time.Month) string {
+	return path.Join(ArchiveDirectory, strconv.Itoa(year), fmt.Sprintf("%02d", int(month)), "index.html")
+}
+
+// Groups entries by the year and month they were created in. Entries are
+// expected to be sorted newest first, and the groups keep that order.
+func buildArchive(entries []blogtemplate.BlogTemplateEntry) []ArchiveYear {
+	years := make([]ArchiveYear, 0)
+
+	for _, e := range entries {
+		year := e.CreatedTime.Year()
+		month := e.CreatedTime.Month()
+
+		if len(years) == 0 || years[len(years) - 1].Year != year {
+			years = append(years, ArchiveYear{
+				Year: year,
+				URL: archiveYearPath(year),
+			})
+		}
+
+		y := &years[len(years) - 1]
+		y.Count++
+
+		if len(y.Months) == 0 || y.Months[len(y.Months) - 1].Month != int(month) {
+			y.Months = append(y.Months, ArchiveMonth{
+				Year: year,
+				Month: int(month),
+				MonthName: month.String(),
+				URL: archiveMonthPath(year, month),
+			})
+		}
+
+		m := &y.Months[len(y.Months) - 1]
+		m.Entries = append(m.Entries, e)
+	}
+
+	return years
+}
+
+// Renders the archive overview and a page for every year and month.
+func writeArchivePages(
+	renderPath string,
+	tmpl *blogtemplate.BlogTemplate,
+	site *SiteContext,
+	pageInput RenderPageInput) error {
+	for i := range site.Archive {
+		year := site.Archive[i]
+
+		input := pageInput
+		input.ArchiveYear = &year
+		input.Entries = make([]blogtemplate.BlogTemplateEntry, 0, year.Count)
+
+		for _, m := range year.Months {
+			input.Entries = append(input.Entries, m.Entries...)
+		}
+
+		page, err := renderPage(tmpl.ArchivePage, site, input, year.URL)
+		if err != nil {
+			return fmt.Errorf("Error encountered while rendering archive page for %v: %w", year.Year, err)
+		}
+
+		err = writePage(renderPath, year.URL, page)
+		if err != nil {
+			return err
+		}
+
+		for j := range year.Months {
+			month := year.Months[j]
+
+			input := pageInput
+			input.ArchiveYear = &year
+			input.ArchiveMonth = &month
+			input.Entries = month.Entries
+
+			page, err := renderPage(tmpl.ArchivePage, site, input, month.URL)
+			if err != nil {
+				return fmt.Errorf("Error encountered while rendering archive page for %v %v: %w", month.MonthName, month.Year, err)
+			}
+
+			err = writePage(renderPath, month.URL, page)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	page, err := renderPage(tmpl.ArchivePage, site, pageInput, site.Pages.Archive)
+	if err != nil {
+		return fmt.Errorf("Error encountered while rendering archive overview page: %w", err)
+	}
+
+	return writePage(renderPath, site.Pages.Archive, page)
+}
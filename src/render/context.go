@@ -0,0 +1,101 @@
+package render
+
+import (
+	"bytes"
+	"html/template"
+	"path"
+	"time"
+
+	"github.com/aghorui/burlough/blog"
+	"github.com/aghorui/burlough/blogtemplate"
+	"github.com/aghorui/burlough/minify"
+	"github.com/aghorui/burlough/util"
+)
+
+// Paths of the well-known pages of the blog, relative to its root.
+type SitePages struct {
+	Front string
+	Index string
+	Tags string
+	Archive string
+}
+
+// Data about the whole blog. It is the same for every page.
+type SiteContext struct {
+	Config blog.ConfigFileParams
+	Entries []blogtemplate.BlogTemplateEntry // Every entry, newest first.
+	Tags []blogtemplate.Tag                  // Every tag used in the blog, with entry counts.
+	Archive []ArchiveYear                    // Every entry grouped by year and month of creation.
+	Pages SitePages
+	Feeds []FeedLink
+	FeedLinks template.HTML                  // Auto-discovery <link> tags for Feeds
+	BuildTime time.Time
+	minified minify.Stats                    // Sizes of the pages minified so far.
+}
+
+// Input given to every template.
+type TemplateContext struct {
+	Site *SiteContext
+	Page any         // RenderBlogPageInput for posts, RenderPageInput for everything else.
+	Root string      // Relative path from the page to the root of the blog ("", "../", ...)
+}
+
+// Prepares the site context for the given entries.
+func prepareSiteContext(
+	params blog.ConfigFileParams,
+	entries []blogtemplate.BlogTemplateEntry) (*SiteContext, error) {
+	feeds, err := getFeedLinks(params)
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	tags, err := collectTags(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SiteContext{
+		Config: params,
+		Entries: entries,
+		Tags: tags,
+		Archive: buildArchive(entries),
+		Pages: SitePages{
+			Front: FrontPageFileName,
+			Index: IndexPageFileName,
+			Tags: path.Join(blogtemplate.TagDirectory, "index.html"),
+			Archive: path.Join(ArchiveDirectory, "index.html"),
+		},
+		Feeds: feeds,
+		FeedLinks: feedLinkTags(feeds),
+		BuildTime: time.Now().UTC(),
+	}, nil
+}
+
+// Executes a page template. pagePath is the path of the page relative to the
+// root of the blog. Pages are minified if enabled.
+func renderPage(
+	t *template.Template,
+	site *SiteContext,
+	page any,
+	pagePath string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	err := t.Execute(&buf, TemplateContext{
+		Site: site,
+		Page: page,
+		Root: rootPrefix(pagePath),
+	})
+
+	if err != nil {
+		return buf.Bytes(), util.Error(err)
+	}
+
+	if site.Config.Minify {
+		page := minify.HTML(buf.Bytes())
+		site.minified.Add(buf.Len(), len(page))
+
+		return page, nil
+	}
+
+	return buf.Bytes(), nil
+}
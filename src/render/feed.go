@@ -0,0 +1,547 @@
+package render
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aghorui/burlough/blog"
+	"github.com/aghorui/burlough/blogtemplate"
+	"github.com/aghorui/burlough/constants"
+	"github.com/aghorui/burlough/util"
+
+	"golang.org/x/net/html"
+)
+
+const RSSFeedFileName  = "feed.xml"
+const AtomFeedFileName = "atom.xml"
+const JSONFeedFileName = "feed.json"
+
+var ErrNoBaseURL = fmt.Errorf("base_url is not set. Set it using 'config set -base_url=<url>'.")
+
+type rssFeed struct {
+	XMLName xml.Name      `xml:"rss"`
+	Version string        `xml:"version,attr"`
+	ContentNS string      `xml:"xmlns:content,attr,omitempty"`
+	Channel rssChannel    `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title string          `xml:"title"`
+	Link string           `xml:"link"`
+	Description string    `xml:"description"`
+	Generator string      `xml:"generator"`
+	LastBuildDate string  `xml:"lastBuildDate,omitempty"`
+	Items []rssItem       `xml:"item"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool      `xml:"isPermaLink,attr"`
+	Value string          `xml:",chardata"`
+}
+
+type rssContent struct {
+	Value string          `xml:",cdata"`
+}
+
+type rssItem struct {
+	Title string          `xml:"title"`
+	Link string           `xml:"link"`
+	GUID rssGUID          `xml:"guid"`
+	PubDate string        `xml:"pubDate"`
+	Description string    `xml:"description"`
+	Content *rssContent   `xml:"content:encoded,omitempty"`
+}
+
+type atomLink struct {
+	Rel string            `xml:"rel,attr,omitempty"`
+	Type string           `xml:"type,attr,omitempty"`
+	Href string           `xml:"href,attr"`
+}
+
+type atomText struct {
+	Type string           `xml:"type,attr,omitempty"`
+	Value string          `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID string             `xml:"id"`
+	Title string          `xml:"title"`
+	Link atomLink         `xml:"link"`
+	Published string      `xml:"published"`
+	Updated string        `xml:"updated"`
+	Summary *atomText     `xml:"summary,omitempty"`
+	Content *atomText     `xml:"content,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomCategory struct {
+	Term string           `xml:"term,attr"`
+}
+
+type atomPerson struct {
+	Name string           `xml:"name"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name      `xml:"http://www.w3.org/2005/Atom feed"`
+	ID string             `xml:"id"`
+	Title string          `xml:"title"`
+	Subtitle string       `xml:"subtitle,omitempty"`
+	Author atomPerson     `xml:"author"`
+	Links []atomLink      `xml:"link"`
+	Updated string        `xml:"updated"`
+	Generator string      `xml:"generator"`
+	Entries []atomEntry   `xml:"entry"`
+}
+
+// See https://www.jsonfeed.org/version/1.1/
+type jsonFeedItem struct {
+	ID string             `json:"id"`
+	URL string            `json:"url"`
+	Title string          `json:"title"`
+	ContentHTML string    `json:"content_html,omitempty"`
+	ContentText string    `json:"content_text,omitempty"`
+	Summary string        `json:"summary,omitempty"`
+	DatePublished string  `json:"date_published"`
+	DateModified string   `json:"date_modified,omitempty"`
+	Tags []string         `json:"tags,omitempty"`
+}
+
+type jsonFeed struct {
+	Version string        `json:"version"`
+	Title string          `json:"title"`
+	HomePageURL string    `json:"home_page_url"`
+	FeedURL string        `json:"feed_url"`
+	Description string    `json:"description,omitempty"`
+	Items []jsonFeedItem  `json:"items"`
+}
+
+// A feed that is generated for the blog. Used for auto-discovery <link> tags.
+type FeedLink struct {
+	Type string  // MIME type of the feed
+	Title string // Human readable name of the feed
+	URL string   // Absolute URL of the feed
+}
+
+// Returns the entries that should go into a feed. Entries are expected to be
+// sorted newest first.
+func feedEntries(params blog.ConfigFileParams, entries []blogtemplate.BlogTemplateEntry) []blogtemplate.BlogTemplateEntry {
+	if params.Feed.MaxItems > 0 && params.Feed.MaxItems < len(entries) {
+		return entries[:params.Feed.MaxItems]
+	}
+
+	return entries
+}
+
+// The time an entry was last changed at. This is the update time if the entry
+// was ever updated, and the creation time otherwise.
+func entryLastModified(e blogtemplate.BlogTemplateEntry) time.Time {
+	if !e.UpdatedTime.IsZero() {
+		return e.UpdatedTime
+	}
+
+	return e.CreatedTime
+}
+
+// The time the newest of the given entries was last changed at.
+func latestModified(entries []blogtemplate.BlogTemplateEntry) time.Time {
+	var latest time.Time
+
+	for _, e := range entries {
+		if t := entryLastModified(e); t.After(latest) {
+			latest = t
+		}
+	}
+
+	return latest
+}
+
+// Resolves a URL in the content of a page against the absolute URL of the
+// page. Absolute URLs are left as they are.
+func resolveFeedURL(base *url.URL, s string) string {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return s
+	}
+
+	return base.ResolveReference(u).String()
+}
+
+// Returns the content of an entry with its relative links and images made
+// absolute, as feed readers show the content away from the page it is on.
+// pageURL is the absolute URL of the page.
+func feedContent(e blogtemplate.BlogTemplateEntry, pageURL string) (string, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", util.Error(err)
+	}
+
+	var out strings.Builder
+
+	z := html.NewTokenizer(strings.NewReader(string(e.Content)))
+
+	for {
+		tt := z.Next()
+
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return out.String(), nil
+			}
+
+			return "", z.Err()
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+
+			for i, a := range t.Attr {
+				switch a.Key {
+				case "href", "src", "poster":
+					t.Attr[i].Val = resolveFeedURL(base, a.Val)
+
+				case "srcset":
+					candidates := strings.Split(a.Val, ",")
+
+					for j, c := range candidates {
+						if fields := strings.Fields(c); len(fields) > 0 {
+							fields[0] = resolveFeedURL(base, fields[0])
+							candidates[j] = strings.Join(fields, " ")
+						}
+					}
+
+					t.Attr[i].Val = strings.Join(candidates, ", ")
+				}
+			}
+
+			out.WriteString(t.String())
+
+		default:
+			out.Write(z.Raw())
+		}
+	}
+}
+
+// Returns the plain text summary of an entry for feeds. The description from
+// the metadata is preferred over the excerpt.
+func entrySummary(e blogtemplate.BlogTemplateEntry) string {
+	if e.Desc != "" {
+		return e.Desc
+	}
+
+	return e.Excerpt
+}
+
+func renderRSSFeed(
+	params blog.ConfigFileParams,
+	entries []blogtemplate.BlogTemplateEntry,
+	buildTime time.Time) ([]byte, error) {
+	siteURL, err := util.JoinURL(params.BaseURL, "")
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title: params.Title,
+			Link: siteURL,
+			Description: params.Desc,
+			Generator: constants.AppName + " " + constants.AppVersion,
+		},
+	}
+
+	if params.Feed.FullContent {
+		feed.ContentNS = "http://purl.org/rss/1.0/modules/content/"
+	}
+
+	entries = feedEntries(params, entries)
+
+	if latest := latestModified(entries); !latest.IsZero() {
+		feed.Channel.LastBuildDate = latest.Format(time.RFC1123Z)
+	}
+
+	for _, e := range entries {
+		link, err := util.JoinURL(params.BaseURL, e.URL)
+		if err != nil {
+			return nil, util.Error(err)
+		}
+
+		item := rssItem{
+			Title: e.Title,
+			Link: link,
+			GUID: rssGUID{ IsPermaLink: true, Value: link },
+			PubDate: e.CreatedTime.Format(time.RFC1123Z),
+			Description: entrySummary(e),
+		}
+
+		if params.Feed.FullContent {
+			content, err := feedContent(e, link)
+			if err != nil {
+				return nil, err
+			}
+
+			item.Content = &rssContent{ Value: content }
+		}
+
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	data, err := xml.MarshalIndent(feed, "", "\t")
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+func renderAtomFeed(
+	params blog.ConfigFileParams,
+	entries []blogtemplate.BlogTemplateEntry,
+	buildTime time.Time) ([]byte, error) {
+	siteURL, err := util.JoinURL(params.BaseURL, "")
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	feedURL, err := util.JoinURL(params.BaseURL, AtomFeedFileName)
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	entries = feedEntries(params, entries)
+
+	feed := atomFeed{
+		ID: siteURL,
+		Title: params.Title,
+		Subtitle: params.Desc,
+		Author: atomPerson{ Name: params.Title },
+		Links: []atomLink{
+			{ Rel: "self", Type: "application/atom+xml", Href: feedURL },
+			{ Rel: "alternate", Type: "text/html", Href: siteURL },
+		},
+		Generator: constants.AppName + " " + constants.AppVersion,
+	}
+
+	// Feeds without entries were last updated when the blog was rendered.
+	if latest := latestModified(entries); !latest.IsZero() {
+		feed.Updated = latest.Format(time.RFC3339)
+	} else {
+		feed.Updated = buildTime.Format(time.RFC3339)
+	}
+
+	for _, e := range entries {
+		link, err := util.JoinURL(params.BaseURL, e.URL)
+		if err != nil {
+			return nil, util.Error(err)
+		}
+
+		entry := atomEntry{
+			ID: link,
+			Title: e.Title,
+			Link: atomLink{ Rel: "alternate", Type: "text/html", Href: link },
+			Published: e.CreatedTime.Format(time.RFC3339),
+			Updated: entryLastModified(e).Format(time.RFC3339),
+		}
+
+		if summary := entrySummary(e); summary != "" {
+			entry.Summary = &atomText{ Type: "text", Value: summary }
+		}
+
+		if params.Feed.FullContent {
+			content, err := feedContent(e, link)
+			if err != nil {
+				return nil, err
+			}
+
+			entry.Content = &atomText{ Type: "html", Value: content }
+		}
+
+		for _, t := range e.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{ Term: t })
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	data, err := xml.MarshalIndent(feed, "", "\t")
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+func renderJSONFeed(
+	params blog.ConfigFileParams,
+	entries []blogtemplate.BlogTemplateEntry,
+	buildTime time.Time) ([]byte, error) {
+	siteURL, err := util.JoinURL(params.BaseURL, "")
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	feedURL, err := util.JoinURL(params.BaseURL, JSONFeedFileName)
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	feed := jsonFeed{
+		Version: "https://jsonfeed.org/version/1.1",
+		Title: params.Title,
+		HomePageURL: siteURL,
+		FeedURL: feedURL,
+		Description: params.Desc,
+		Items: []jsonFeedItem{},
+	}
+
+	for _, e := range feedEntries(params, entries) {
+		link, err := util.JoinURL(params.BaseURL, e.URL)
+		if err != nil {
+			return nil, util.Error(err)
+		}
+
+		item := jsonFeedItem{
+			ID: link,
+			URL: link,
+			Title: e.Title,
+			Summary: entrySummary(e),
+			DatePublished: e.CreatedTime.Format(time.RFC3339),
+			Tags: e.Tags,
+		}
+
+		if !e.UpdatedTime.IsZero() {
+			item.DateModified = e.UpdatedTime.Format(time.RFC3339)
+		}
+
+		// Every item needs either HTML or text content.
+		if params.Feed.FullContent {
+			content, err := feedContent(e, link)
+			if err != nil {
+				return nil, err
+			}
+
+			item.ContentHTML = content
+		} else {
+			item.ContentText = entrySummary(e)
+		}
+
+		feed.Items = append(feed.Items, item)
+	}
+
+	data, err := json.MarshalIndent(feed, "", "\t")
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	return data, nil
+}
+
+// Feed formats that can be generated, in the order they are advertised.
+var feedFormats = []struct {
+	FileName string
+	Type string
+	Title string
+	Enabled func(blog.FeedParams) bool
+	Render func(blog.ConfigFileParams, []blogtemplate.BlogTemplateEntry, time.Time) ([]byte, error)
+}{
+	{ RSSFeedFileName, "application/rss+xml", "RSS", func(f blog.FeedParams) bool { return f.RSS }, renderRSSFeed },
+	{ AtomFeedFileName, "application/atom+xml", "Atom", func(f blog.FeedParams) bool { return f.Atom }, renderAtomFeed },
+	{ JSONFeedFileName, "application/feed+json", "JSON Feed", func(f blog.FeedParams) bool { return f.JSON }, renderJSONFeed },
+}
+
+// Returns true if any feed is enabled in the config.
+func feedsEnabled(params blog.ConfigFileParams) bool {
+	for _, f := range feedFormats {
+		if f.Enabled(params.Feed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Lists the feeds that will be generated. Feeds need absolute URLs, so
+// nothing is generated if there is no base URL.
+func getFeedLinks(params blog.ConfigFileParams) ([]FeedLink, error) {
+	if params.BaseURL == "" {
+		return nil, nil
+	}
+
+	links := make([]FeedLink, 0, len(feedFormats))
+
+	for _, f := range feedFormats {
+		if !f.Enabled(params.Feed) {
+			continue
+		}
+
+		u, err := util.JoinURL(params.BaseURL, f.FileName)
+		if err != nil {
+			return nil, util.Error(err)
+		}
+
+		links = append(links, FeedLink{
+			Type: f.Type,
+			Title: params.Title + " (" + f.Title + ")",
+			URL: u,
+		})
+	}
+
+	return links, nil
+}
+
+// Generates the auto-discovery <link> tags for the given feeds.
+func feedLinkTags(links []FeedLink) template.HTML {
+	var b strings.Builder
+
+	for _, l := range links {
+		fmt.Fprintf(&b, "<link rel=\"alternate\" type=\"%v\" title=\"%v\" href=\"%v\" />\n",
+			template.HTMLEscapeString(l.Type),
+			template.HTMLEscapeString(l.Title),
+			template.HTMLEscapeString(l.URL))
+	}
+
+	return template.HTML(b.String())
+}
+
+// Generates every enabled feed into renderPath. buildTime is used as the
+// update time of feeds that have no entries.
+func writeFeeds(
+	renderPath string,
+	params blog.ConfigFileParams,
+	entries []blogtemplate.BlogTemplateEntry,
+	buildTime time.Time) error {
+	if !feedsEnabled(params) {
+		return nil
+	}
+
+	if params.BaseURL == "" {
+		fmt.Fprintf(os.Stderr, "Warning: skipping feed generation: %v\n", ErrNoBaseURL)
+		return nil
+	}
+
+	for _, f := range feedFormats {
+		if !f.Enabled(params.Feed) {
+			continue
+		}
+
+		feed, err := f.Render(params, entries, buildTime)
+		if err != nil {
+			return util.Error(err)
+		}
+
+		err = os.WriteFile(filepath.Join(renderPath, f.FileName), feed, 0644)
+		if err != nil {
+			return fmt.Errorf("Error encountered while writing %v feed: %w", f.Title, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aghorui/burlough/blog"
+	"github.com/aghorui/burlough/blogtemplate"
+	"github.com/aghorui/burlough/util"
+)
+
+const GraphFileName = "graph.json"
+
+// A post in the link graph.
+type GraphNode struct {
+	ID string    `json:"id"`    // Path of the blog file.
+	Title string `json:"title"`
+	URL string   `json:"url"`   // Path of the rendered page, relative to the root of the blog.
+	Inbound int  `json:"inbound"`
+	Outbound int `json:"outbound"`
+}
+
+// A link from one post to another.
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// The links between posts. A post linking to another more than once has a
+// single edge, and links from a post to itself are left out.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// Creates the link graph of blog files from the links recorded while
+// rendering their contents.
+func newGraph(files []blog.BlogMetadata, pages []blog.BlogFileContents, links *linkTable) Graph {
+	g := Graph{
+		Nodes: make([]GraphNode, 0, len(files)),
+		Edges: make([]GraphEdge, 0),
+	}
+
+	for i, file := range files {
+		g.Nodes = append(g.Nodes, GraphNode{
+			ID: filepath.ToSlash(file.Path),
+			Title: pages[i].Title,
+			URL: OutputPath(file),
+		})
+	}
+
+	for to, froms := range links.backlinks() {
+		for _, from := range froms {
+			g.Nodes[from].Outbound++
+			g.Nodes[to].Inbound++
+		}
+	}
+
+	for from, tos := range links.links {
+		seen := make(map[int]bool)
+
+		for _, to := range tos {
+			if to == from || seen[to] {
+				continue
+			}
+
+			seen[to] = true
+			g.Edges = append(g.Edges, GraphEdge{ Source: g.Nodes[from].ID, Target: g.Nodes[to].ID })
+		}
+	}
+
+	return g
+}
+
+// Parses every blog file and resolves its links to find the links between
+// them. Images are not resized or copied.
+func BuildGraph(
+	basePath string,
+	tmpl *blogtemplate.BlogTemplate,
+	params blog.ConfigFileParams) (Graph, error) {
+	pages, links, err := resolveContents(basePath, tmpl, params)
+	if err != nil {
+		return Graph{}, err
+	}
+
+	return newGraph(params.Files, pages, links), nil
+}
+
+// Returns the posts no other post links to.
+func (g Graph) Orphans() []GraphNode {
+	orphans := make([]GraphNode, 0)
+
+	for _, n := range g.Nodes {
+		if n.Inbound == 0 {
+			orphans = append(orphans, n)
+		}
+	}
+
+	return orphans
+}
+
+// Returns the posts linking to no other post.
+func (g Graph) DeadEnds() []GraphNode {
+	deadEnds := make([]GraphNode, 0)
+
+	for _, n := range g.Nodes {
+		if n.Outbound == 0 {
+			deadEnds = append(deadEnds, n)
+		}
+	}
+
+	return deadEnds
+}
+
+// Returns the graph as JSON.
+func (g Graph) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(g, "", "\t")
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	return append(data, '\n'), nil
+}
+
+// Quotes a string as a DOT identifier. Only quotes and backslashes are
+// escaped, as DOT does not know other escapes.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	return "\"" + strings.ReplaceAll(s, "\"", "\\\"") + "\""
+}
+
+// Returns the graph in the Graphviz DOT format. Posts are labelled with their
+// titles.
+func (g Graph) DOT() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("digraph posts {\n")
+
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&buf, "\t%v [label=%v];\n", dotQuote(n.ID), dotQuote(n.Title))
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&buf, "\t%v -> %v;\n", dotQuote(e.Source), dotQuote(e.Target))
+	}
+
+	buf.WriteString("}\n")
+
+	return buf.Bytes()
+}
+
+func writeGraph(renderPath string, params blog.ConfigFileParams, g Graph) error {
+	if !params.Graph {
+		return nil
+	}
+
+	data, err := g.JSON()
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(filepath.Join(renderPath, GraphFileName), data, 0644)
+	if err != nil {
+		return fmt.Errorf("Error encountered while writing link graph: %w", err)
+	}
+
+	return nil
+}
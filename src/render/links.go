@@ -0,0 +1,451 @@
+package render
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aghorui/burlough/blog"
+	"github.com/aghorui/burlough/blogtemplate"
+	"github.com/aghorui/burlough/images"
+	"github.com/aghorui/burlough/parse"
+	"github.com/aghorui/burlough/util"
+)
+
+// Returns the path a blog file is rendered to, relative to the root of the
+// rendered blog. Page bundles are rendered to <directory>/index.html.
+func OutputPath(file blog.BlogMetadata) string {
+	return util.ExtractFilename(filepath.ToSlash(file.Path)) + ".html"
+}
+
+// Returns the name of a blog file without its extension, or the name of the
+// directory of a page bundle.
+func fileName(file blog.BlogMetadata) string {
+	p := filepath.ToSlash(file.Path)
+
+	if file.Bundle {
+		return path.Base(path.Dir(p))
+	}
+
+	return util.ExtractFilename(path.Base(p))
+}
+
+// Returns the URL of target from the page at pagePath. Both are relative to
+// the root of the blog.
+func relativeURL(pagePath string, target string) string {
+	dir := path.Dir(pagePath)
+
+	if dir == "." {
+		return target
+	}
+
+	if strings.HasPrefix(target, dir + "/") {
+		return strings.TrimPrefix(target, dir + "/")
+	}
+
+	return rootPrefix(pagePath) + target
+}
+
+// Returns the paths of the files in a page bundle other than its index.md,
+// relative to basePath.
+func bundleResources(basePath string, file blog.BlogMetadata) ([]string, error) {
+	dir := path.Dir(filepath.ToSlash(file.Path))
+	resources := make([]string, 0)
+
+	err := filepath.WalkDir(filepath.Join(basePath, filepath.FromSlash(dir)), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(basePath, p)
+		if err != nil {
+			return err
+		}
+
+		if rel = filepath.ToSlash(rel); rel != filepath.ToSlash(file.Path) {
+			resources = append(resources, rel)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	return resources, nil
+}
+
+// Tracked blog files that links can point to, and the links and local files
+// found in them while rendering.
+type linkTable struct {
+	basePath string
+	files []blog.BlogMetadata
+	lenient bool            // Warn about broken links instead of failing.
+	paths map[string]int    // File indices by path.
+	names map[string][]int  // File indices by lowercase file name, without the extension.
+	titles map[string][]int // File indices by lowercase title.
+	links [][]int           // Indices of the files each file links to, in order of appearance.
+	resources map[string]bool // Paths of the local files used by the files.
+	variants map[string]string // Cached resized images by the paths they are copied to.
+}
+
+// Creates a link table for blog files with the given titles.
+func newLinkTable(basePath string, files []blog.BlogMetadata, titles []string, lenient bool) *linkTable {
+	t := &linkTable{
+		basePath: basePath,
+		files: files,
+		lenient: lenient,
+		paths: make(map[string]int, len(files)),
+		names: make(map[string][]int),
+		titles: make(map[string][]int),
+		links: make([][]int, len(files)),
+		resources: make(map[string]bool),
+		variants: make(map[string]string),
+	}
+
+	for i, file := range files {
+		p := path.Clean(filepath.ToSlash(file.Path))
+		name := strings.ToLower(fileName(file))
+
+		t.paths[p] = i
+		t.names[name] = append(t.names[name], i)
+
+		if i < len(titles) {
+			title := strings.ToLower(strings.TrimSpace(titles[i]))
+			t.titles[title] = append(t.titles[title], i)
+		}
+	}
+
+	return t
+}
+
+// Returns the path a link in files[from] points to, relative to the base
+// path. Paths are relative to the linking file, or to the base path if they
+// start with '/'.
+func (t *linkTable) resolvePath(from int, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return path.Clean(strings.TrimPrefix(target, "/"))
+	}
+
+	return path.Join(path.Dir(filepath.ToSlash(t.files[from].Path)), target)
+}
+
+// Records a link and returns the URL of the linked file relative to the
+// linking one.
+func (t *linkTable) link(from int, to int) string {
+	t.links[from] = append(t.links[from], to)
+	return relativeURL(OutputPath(t.files[from]), OutputPath(t.files[to]))
+}
+
+// Returns the error for a broken link, or prints it as a warning and returns
+// nil in lenient mode.
+func (t *linkTable) broken(from int, err error) error {
+	if !t.lenient {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: %v: %v\n", t.files[from].Path, err)
+
+	return nil
+}
+
+// Returns a resolver for links from files[from] to markdown files. Paths are
+// relative to the linking file, or to the base path if they start with '/'.
+// Links to files that are missing or not tracked are an error, or a warning
+// that leaves the link as it is in lenient mode.
+func (t *linkTable) fileResolver(from int) parse.LinkResolver {
+	return func(target string) (string, error) {
+		resolved := t.resolvePath(from, target)
+
+		if to, ok := t.paths[resolved]; ok {
+			return t.link(from, to), nil
+		}
+
+		var err error
+
+		if _, statErr := os.Stat(filepath.Join(t.basePath, filepath.FromSlash(resolved))); statErr == nil {
+			err = fmt.Errorf("link to '%v', which is not tracked", target)
+		} else {
+			err = fmt.Errorf("link to '%v', which does not exist", target)
+		}
+
+		return target, t.broken(from, err)
+	}
+}
+
+// Returns a resolver for wiki links from files[from]. Targets are matched
+// against the paths of the files (with or without the extension), then their
+// file names, then their titles, ignoring case. Targets matching nothing or
+// more than one file are an error, or a warning that leaves the link as text
+// in lenient mode.
+func (t *linkTable) wikiResolver(from int) parse.LinkResolver {
+	return func(target string) (string, error) {
+		key := path.Clean(filepath.ToSlash(strings.TrimSpace(target)))
+
+		if to, ok := t.paths[key]; ok {
+			return t.link(from, to), nil
+		}
+
+		if to, ok := t.paths[key + ".md"]; ok {
+			return t.link(from, to), nil
+		}
+
+		for _, index := range []map[string][]int{ t.names, t.titles } {
+			matches := index[strings.ToLower(strings.TrimSpace(target))]
+
+			if len(matches) == 1 {
+				return t.link(from, matches[0]), nil
+			}
+
+			if len(matches) > 1 {
+				return "", t.broken(from, fmt.Errorf("wiki link to '%v', which matches more than one file", target))
+			}
+		}
+
+		return "", t.broken(from, fmt.Errorf("wiki link to '%v', which matches no file", target))
+	}
+}
+
+// Returns the path of a local file used by files[from], relative to the base
+// path. Paths are relative to the linking file, or to the base path if they
+// start with '/'. Files that are missing or outside of the base path are an
+// error.
+func (t *linkTable) resourcePath(from int, target string) (string, error) {
+	resolved := t.resolvePath(from, target)
+
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", fmt.Errorf("'%v' is outside of the blog directory", target)
+	}
+
+	info, err := os.Stat(filepath.Join(t.basePath, filepath.FromSlash(resolved)))
+	if err != nil {
+		return "", fmt.Errorf("'%v' does not exist", target)
+	}
+
+	if info.IsDir() {
+		return "", fmt.Errorf("'%v' is a directory", target)
+	}
+
+	return resolved, nil
+}
+
+// Records a local file to be copied to the same path in the rendered blog and
+// returns its URL relative to files[from].
+func (t *linkTable) resource(from int, resolved string) string {
+	t.resources[resolved] = true
+	return relativeURL(OutputPath(t.files[from]), resolved)
+}
+
+// Returns a resolver for local files used by images and shortcodes in
+// files[from]. The files are recorded to be copied to the same path in the
+// rendered blog. Files that are missing or outside of the base path are an
+// error, or a warning that leaves the path as it is in lenient mode.
+func (t *linkTable) resourceResolver(from int) parse.LinkResolver {
+	return func(target string) (string, error) {
+		resolved, err := t.resourcePath(from, target)
+		if err != nil {
+			return target, t.broken(from, err)
+		}
+
+		return t.resource(from, resolved), nil
+	}
+}
+
+// Returns a resolver for links from files[from] to local files that are not
+// pages, such as attachments. Files in the base path are recorded to be
+// copied like resources. Links to anything else, such as /rss.xml and other
+// files made while rendering, are left as they are.
+func (t *linkTable) attachmentResolver(from int) parse.LinkResolver {
+	return func(target string) (string, error) {
+		resolved, err := t.resourcePath(from, target)
+		if err != nil {
+			return target, nil
+		}
+
+		return t.resource(from, resolved), nil
+	}
+}
+
+// Returns a resolver adding the dimensions of local images used by
+// files[from], srcset and sizes if resized copies are made of them, and
+// loading="lazy". The copies are recorded to be copied next to the images in
+// the rendered blog, with the width added to their names. Images the resource
+// resolver rejects are left alone.
+func (t *linkTable) imageResolver(from int, processor *images.Processor, params blog.ImageParams) parse.ImageResolver {
+	return func(target string) ([]parse.ImageAttribute, error) {
+		resolved := t.resolvePath(from, target)
+		src := filepath.Join(t.basePath, filepath.FromSlash(resolved))
+		attrs := make([]parse.ImageAttribute, 0)
+
+		if info, err := os.Stat(src); err != nil || info.IsDir() || resolved == ".." || strings.HasPrefix(resolved, "../") {
+			return attrs, nil
+		}
+
+		if images.IsImage(resolved) {
+			width, height, err := images.Size(src)
+			if err != nil {
+				return nil, fmt.Errorf("'%v' could not be read as an image: %v", target, err)
+			}
+
+			attrs = append(attrs,
+				parse.ImageAttribute{ Name: "width", Value: strconv.Itoa(width) },
+				parse.ImageAttribute{ Name: "height", Value: strconv.Itoa(height) })
+
+			variants, err := processor.Variants(src)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(variants) > 0 {
+				pagePath := OutputPath(t.files[from])
+				ext := path.Ext(resolved)
+				srcset := make([]string, 0, len(variants) + 1)
+
+				for _, v := range variants {
+					dest := fmt.Sprintf("%v-%vw%v", strings.TrimSuffix(resolved, ext), v.Width, ext)
+					t.variants[dest] = v.Path
+					srcset = append(srcset, fmt.Sprintf("%v %vw", srcsetURL(pagePath, dest), v.Width))
+				}
+
+				srcset = append(srcset, fmt.Sprintf("%v %vw", srcsetURL(pagePath, resolved), width))
+
+				attrs = append(attrs, parse.ImageAttribute{ Name: "srcset", Value: strings.Join(srcset, ", ") })
+
+				if params.Sizes != "" {
+					attrs = append(attrs, parse.ImageAttribute{ Name: "sizes", Value: params.Sizes })
+				}
+			}
+		}
+
+		if params.Lazy {
+			attrs = append(attrs, parse.ImageAttribute{ Name: "loading", Value: "lazy" })
+		}
+
+		return attrs, nil
+	}
+}
+
+// Returns the URL of target from the page at pagePath for use in a srcset,
+// where spaces and commas separate candidates.
+func srcsetURL(pagePath string, target string) string {
+	u := url.URL{ Path: relativeURL(pagePath, target) }
+	return strings.ReplaceAll(u.String(), ",", "%2C")
+}
+
+// Returns the paths of the local files used by the files, sorted.
+func (t *linkTable) resourcePaths() []string {
+	paths := make([]string, 0, len(t.resources))
+
+	for p := range t.resources {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+// Copies local files used by posts from the base path to the same paths in
+// the rendered blog. With stripMetadata, EXIF and XMP metadata is removed from
+// JPEG and PNG images, and the images that held a location are printed.
+func copyResources(basePath string, renderPath string, resources []string, stripMetadata bool) error {
+	for _, resource := range resources {
+		src := filepath.Join(basePath, filepath.FromSlash(resource))
+		dest := filepath.Join(renderPath, filepath.FromSlash(resource))
+
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return util.Error(err)
+		}
+
+		if stripMetadata {
+			var m images.Metadata
+
+			data, m, err = images.StripMetadata(data)
+			if err != nil {
+				return fmt.Errorf("Error encountered while removing metadata from %v: %w", src, err)
+			}
+
+			if m.Location {
+				fmt.Fprintf(os.Stderr, "Removed location data from %v\n", resource)
+			}
+		}
+
+		err = writePage(renderPath, resource, data)
+		if err != nil {
+			return fmt.Errorf("Error encountered while copying %v to %v: %w", src, dest, err)
+		}
+	}
+
+	return nil
+}
+
+// Copies cached resized images to the paths they were recorded for in the
+// rendered blog.
+func copyVariants(renderPath string, variants map[string]string) error {
+	for dest, src := range variants {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return util.Error(err)
+		}
+
+		err = writePage(renderPath, dest, data)
+		if err != nil {
+			return fmt.Errorf("Error encountered while copying %v to %v: %w", src, dest, err)
+		}
+	}
+
+	return nil
+}
+
+// Returns the indices of the files linking to each file, without duplicates.
+func (t *linkTable) backlinks() [][]int {
+	backlinks := make([][]int, len(t.files))
+
+	for from, links := range t.links {
+		seen := make(map[int]bool)
+
+		for _, to := range links {
+			if to == from || seen[to] {
+				continue
+			}
+
+			seen[to] = true
+			backlinks[to] = append(backlinks[to], from)
+		}
+	}
+
+	return backlinks
+}
+
+// Parses every blog file and returns the links and images in its content, as
+// they are rendered, by the path of the page it is rendered to. Images are not
+// resized or copied.
+func PageLinks(
+	basePath string,
+	tmpl *blogtemplate.BlogTemplate,
+	params blog.ConfigFileParams) (map[string][]blog.Link, error) {
+	pages, _, err := resolveContents(basePath, tmpl, params)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make(map[string][]blog.Link, len(pages))
+
+	for i, file := range params.Files {
+		links[OutputPath(file)] = pages[i].Links
+	}
+
+	return links, nil
+}
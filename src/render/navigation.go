@@ -0,0 +1,97 @@
+package render
+
+import (
+	"sort"
+
+	"github.com/aghorui/burlough/blog"
+	"github.com/aghorui/burlough/blogtemplate"
+)
+
+// Input given to the blog page template. The entry's fields can be used
+// directly, along with the entries around it.
+type RenderBlogPageInput struct {
+	blogtemplate.BlogTemplateEntry
+	Prev *blogtemplate.BlogTemplateEntry      // The next older entry. nil if this is the oldest.
+	Next *blogtemplate.BlogTemplateEntry      // The next newer entry. nil if this is the newest.
+	Related []blogtemplate.BlogTemplateEntry  // Entries sharing tags with this one, most shared first.
+	Backlinks []blogtemplate.BlogTemplateEntry // Entries linking to this one.
+}
+
+// Returns the number of tags two entries have in common.
+func sharedTagCount(a blogtemplate.BlogTemplateEntry, b blogtemplate.BlogTemplateEntry) int {
+	count := 0
+
+	for _, ta := range a.TagLinks {
+		for _, tb := range b.TagLinks {
+			if ta.Slug == tb.Slug {
+				count++
+				break
+			}
+		}
+	}
+
+	return count
+}
+
+// Finds up to maxCount entries that share tags with entries[index]. Entries
+// sharing more tags come first, ties are broken by the order of entries.
+func findRelated(entries []blogtemplate.BlogTemplateEntry, index int, maxCount int) []blogtemplate.BlogTemplateEntry {
+	if maxCount <= 0 {
+		return nil
+	}
+
+	type candidate struct {
+		index int
+		shared int
+	}
+
+	candidates := make([]candidate, 0)
+
+	for i := range entries {
+		if i == index {
+			continue
+		}
+
+		if shared := sharedTagCount(entries[index], entries[i]); shared > 0 {
+			candidates = append(candidates, candidate{ i, shared })
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].shared > candidates[j].shared
+	})
+
+	if len(candidates) > maxCount {
+		candidates = candidates[:maxCount]
+	}
+
+	related := make([]blogtemplate.BlogTemplateEntry, 0, len(candidates))
+
+	for _, c := range candidates {
+		related = append(related, entries[c.index])
+	}
+
+	return related
+}
+
+// Prepares the input of the blog page for entries[index]. Entries are
+// expected to be sorted newest first.
+func prepareBlogPageInput(
+	params blog.ConfigFileParams,
+	entries []blogtemplate.BlogTemplateEntry,
+	index int) RenderBlogPageInput {
+	input := RenderBlogPageInput{
+		BlogTemplateEntry: entries[index],
+		Related: findRelated(entries, index, params.RelatedPosts),
+	}
+
+	if index + 1 < len(entries) {
+		input.Prev = &entries[index + 1]
+	}
+
+	if index > 0 {
+		input.Next = &entries[index - 1]
+	}
+
+	return input
+}
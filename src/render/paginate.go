@@ -0,0 +1,131 @@
+package render
+
+import (
+	"html/template"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// A page in a paginated listing.
+type PaginatorPage struct {
+	Number int
+	URL string
+	Current bool
+}
+
+// Pagination state of a listing page. URLs are relative to the root of the
+// blog, and are empty if there is no such page.
+type Paginator struct {
+	Page int             // Current page number, starting from 1
+	TotalPages int
+	PageSize int         // Maximum number of entries on a page. 0 means unlimited.
+	TotalEntries int     // Number of entries over all pages.
+	URL string
+	PrevURL string
+	NextURL string
+	FirstURL string
+	LastURL string
+	Pages []PaginatorPage
+}
+
+// Returns the path of a page of a listing. The first page keeps basePath
+// ("blog_index.html"), later pages go into a directory named after it
+// ("blog_index/2.html").
+func paginatedPagePath(basePath string, page int) string {
+	if page <= 1 {
+		return basePath
+	}
+
+	return path.Join(strings.TrimSuffix(basePath, ".html"), strconv.Itoa(page) + ".html")
+}
+
+// Creates the paginator for every page of a listing with the given number of
+// entries.
+func paginate(basePath string, totalEntries int, pageSize int) []Paginator {
+	totalPages := 1
+
+	if pageSize > 0 && totalEntries > pageSize {
+		totalPages = (totalEntries + pageSize - 1) / pageSize
+	}
+
+	pages := make([]PaginatorPage, totalPages)
+
+	for i := range pages {
+		pages[i] = PaginatorPage{
+			Number: i + 1,
+			URL: paginatedPagePath(basePath, i + 1),
+		}
+	}
+
+	ret := make([]Paginator, totalPages)
+
+	for i := range ret {
+		p := Paginator{
+			Page: i + 1,
+			TotalPages: totalPages,
+			PageSize: pageSize,
+			TotalEntries: totalEntries,
+			URL: pages[i].URL,
+			FirstURL: pages[0].URL,
+			LastURL: pages[totalPages - 1].URL,
+			Pages: make([]PaginatorPage, totalPages),
+		}
+
+		copy(p.Pages, pages)
+		p.Pages[i].Current = true
+
+		if i > 0 {
+			p.PrevURL = pages[i - 1].URL
+		}
+
+		if i < totalPages - 1 {
+			p.NextURL = pages[i + 1].URL
+		}
+
+		ret[i] = p
+	}
+
+	return ret
+}
+
+// Splits input.Entries into pages of pageSize entries and renders each of them
+// with t. The first page is written to basePath.
+func writePaginatedPages(
+	renderPath string,
+	basePath string,
+	pageSize int,
+	t *template.Template,
+	site *SiteContext,
+	input RenderPageInput) error {
+	entries := input.Entries
+
+	for _, p := range paginate(basePath, len(entries), pageSize) {
+		p := p
+		pageInput := input
+		pageInput.Paginator = &p
+
+		if pageSize > 0 {
+			start := (p.Page - 1) * pageSize
+			end := start + pageSize
+
+			if end > len(entries) {
+				end = len(entries)
+			}
+
+			pageInput.Entries = entries[start:end]
+		}
+
+		page, err := renderPage(t, site, pageInput, p.URL)
+		if err != nil {
+			return err
+		}
+
+		err = writePage(renderPath, p.URL, page)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
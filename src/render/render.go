@@ -1,74 +1,179 @@
 package render
 
 import (
-	"bytes"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/aghorui/burlough/blog"
 	"github.com/aghorui/burlough/blogtemplate"
+	"github.com/aghorui/burlough/images"
 	"github.com/aghorui/burlough/parse"
 	"github.com/aghorui/burlough/util"
 )
 
+const FrontPageFileName = "index.html"
+const IndexPageFileName = "blog_index.html"
+
+// Page input given to listing pages (front page, index, tag and archive
+// pages).
 type RenderPageInput struct {
 	Title string
 	Desc string
 	Tags blog.Tags
-	Entries []blogtemplate.BlogTemplateEntry
+	Entries []blogtemplate.BlogTemplateEntry // Entries listed on the page.
+	Tag *blogtemplate.Tag   // Tag of a tag page. nil on every other page.
+	Paginator *Paginator    // Pagination state of listing pages. nil on the front page.
+	ArchiveYear *ArchiveYear   // Year of a yearly or monthly archive page. nil on every other page.
+	ArchiveMonth *ArchiveMonth // Month of a monthly archive page. nil on every other page.
 }
 
-func renderIndexPage(
-		t *blogtemplate.BlogTemplate,
-		params blog.ConfigFileParams,
-		entries []blogtemplate.BlogTemplateEntry) ([]byte, error) {
-	var buf bytes.Buffer
-	err := t.IndexPage.Execute(&buf, RenderPageInput{
-		Title: params.Title,
-		Desc: params.Desc,
-		Tags: params.Tags,
-		Entries: entries,
-	})
+// Returns the relative path from a page to the root of the blog. pagePath is
+// relative to the root as well.
+func rootPrefix(pagePath string) string {
+	depth := strings.Count(path.Clean(filepath.ToSlash(pagePath)), "/")
+	return strings.Repeat("../", depth)
+}
+
+// Writes a page to a path relative to renderPath, creating directories as
+// needed.
+func writePage(renderPath string, pagePath string, data []byte) error {
+	finalPath := filepath.Join(renderPath, filepath.FromSlash(pagePath))
+
+	err := os.MkdirAll(filepath.Dir(finalPath), 0755)
+	if err != nil {
+		return util.Error(err)
+	}
 
+	err = os.WriteFile(finalPath, data, 0644)
 	if err != nil {
-		return buf.Bytes(), util.Error(err)
+		return fmt.Errorf("Error encountered while writing %v: %w", finalPath, err)
 	}
 
-	return buf.Bytes(), nil
+	return nil
 }
 
-func renderFrontPage(
-	t *blogtemplate.BlogTemplate,
-	params blog.ConfigFileParams,
-	entries []blogtemplate.BlogTemplateEntry) ([]byte, error) {
-	var buf bytes.Buffer
+// Parses every blog file and creates the link table their links are resolved
+// with.
+func parseContents(
+	basePath string,
+	tmpl *blogtemplate.BlogTemplate,
+	params blog.ConfigFileParams) ([]*parse.Document, *linkTable, error) {
+	mdParser, err := parse.NewParser(params.Markdown, tmpl.Shortcodes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error encountered while setting up the markdown parser: %w", err)
+	}
 
-	err := t.FrontPage.Execute(&buf, RenderPageInput{
-		Title: params.Title,
-		Desc: params.Desc,
-		Tags: params.Tags,
-		Entries: entries,
-	})
+	documents := make([]*parse.Document, 0, len(params.Files))
+	titles := make([]string, 0, len(params.Files))
 
-	if err != nil {
-		return buf.Bytes(), util.Error(err)
+	for index, file := range params.Files {
+		fmt.Fprintf(os.Stderr, "Processing %v (%v/%v)\n", file.Path, index + 1, len(params.Files))
+
+		data, err := os.ReadFile(filepath.Join(basePath, file.Path))
+		if err != nil {
+			return nil, nil, util.Error(err)
+		}
+
+		document, err := mdParser.ParseDocument(data)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error encountered while parsing %v: %w", file.Path, err)
+		}
+
+		if document.NoMetadata {
+			fmt.Fprintf(os.Stderr, "Warning: file %v has no metadata.\n", file.Path)
+		}
+
+		documents = append(documents, document)
+		titles = append(titles, document.Contents.Title)
 	}
 
-	return buf.Bytes(), nil
+	return documents, newLinkTable(basePath, params.Files, titles, params.LenientLinks), nil
 }
 
-func renderBlogPage(
-	t *blogtemplate.BlogTemplate,
-	page blogtemplate.BlogTemplateEntry) ([]byte, error) {
-	var buf bytes.Buffer
-	err := t.BlogPage.Execute(&buf, page)
+// Parses every blog file and renders its content. Links between the files
+// are resolved once all of them are parsed, and are recorded in the returned
+// link table.
+func renderContents(
+	basePath string,
+	tmpl *blogtemplate.BlogTemplate,
+	params blog.ConfigFileParams) ([]blog.BlogFileContents, *linkTable, error) {
+	documents, links, err := parseContents(basePath, tmpl, params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Page bundles are copied as a whole, including files no link points to.
+	for _, file := range params.Files {
+		if !file.Bundle {
+			continue
+		}
+
+		resources, err := bundleResources(basePath, file)
+		if err != nil {
+			return nil, nil, err
+		}
 
+		for _, resource := range resources {
+			links.resources[resource] = true
+		}
+	}
+
+	processor := images.NewProcessor(params.Images.Widths, images.DefaultCacheDir())
+	pages := make([]blog.BlogFileContents, 0, len(params.Files))
+
+	for index, file := range params.Files {
+		page, err := documents[index].Render(parse.Resolvers{
+			Links: links.fileResolver(index),
+			WikiLinks: links.wikiResolver(index),
+			Resources: links.resourceResolver(index),
+			Attachments: links.attachmentResolver(index),
+			Images: links.imageResolver(index, processor, params.Images),
+		})
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error encountered while parsing %v: %w", file.Path, err)
+		}
+
+		pages = append(pages, page)
+	}
+
+	return pages, links, nil
+}
+
+// Parses every blog file and renders its content, resolving only the links
+// to other blog files and to attachments. Images and the files used by
+// shortcodes are left as they are, so nothing is resized and they do not have
+// to exist.
+func resolveContents(
+	basePath string,
+	tmpl *blogtemplate.BlogTemplate,
+	params blog.ConfigFileParams) ([]blog.BlogFileContents, *linkTable, error) {
+	documents, links, err := parseContents(basePath, tmpl, params)
 	if err != nil {
-		return buf.Bytes(), util.Error(err)
+		return nil, nil, err
 	}
 
-	return buf.Bytes(), nil
+	pages := make([]blog.BlogFileContents, 0, len(params.Files))
+
+	for index, file := range params.Files {
+		page, err := documents[index].Render(parse.Resolvers{
+			Links: links.fileResolver(index),
+			WikiLinks: links.wikiResolver(index),
+			Attachments: links.attachmentResolver(index),
+		})
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error encountered while parsing %v: %w", file.Path, err)
+		}
+
+		pages = append(pages, page)
+	}
+
+	return pages, links, nil
 }
 
 // Renders/Exports the project.
@@ -87,7 +192,7 @@ func Render(
 		renderPath = params.RenderPath
 	}
 
-	err := tmpl.CopyAssetsToFolder(renderPath)
+	assetStats, err := tmpl.CopyAssetsToFolder(renderPath, params.Markdown, params.Minify)
 	if err != nil {
 		return util.Error(err)
 	}
@@ -97,73 +202,121 @@ func Render(
 		return util.Error(err)
 	}
 
-	// Prepare all articles
-	for index, file := range params.Files {
-		fmt.Fprintf(os.Stderr, "Processing %v (%v/%v)\n", file.Path, index + 1, len(params.Files))
-
-		data, err := os.ReadFile(filepath.Join(basePath, file.Path))
-		if err != nil {
-			return util.Error(err)
-		}
-
-		finalPath := util.ExtractFilename(file.Path) + ".html"
+	pages, links, err := renderContents(basePath, tmpl, params)
+	if err != nil {
+		return err
+	}
 
-		page, noMetadata, err := parse.ParseBlogFile(data)
+	err = copyResources(basePath, renderPath, links.resourcePaths(), params.Images.StripMetadata)
+	if err != nil {
+		return err
+	}
 
-		if err != nil {
-			return fmt.Errorf("Error encountered while parsing %v: %w", file.Path, err)
-		}
+	err = copyVariants(renderPath, links.variants)
+	if err != nil {
+		return err
+	}
 
-		if noMetadata {
-			fmt.Fprintf(os.Stderr, "Warning: file %v has no metadata.\n", file.Path)
-		}
+	// Prepare all articles
+	for index, file := range params.Files {
+		page := pages[index]
 
 		te := blogtemplate.PrepareBlogTemplateEntry(blog.BlogFile{
 			BlogMetadata: file,
 			BlogFileContents: page,
-		}, finalPath, params.Desc, params.Tags)
+		}, OutputPath(file), params.Desc, params.Tags)
+
+		te.TOC = blogtemplate.BuildTOC(page.Headings, params.TOC.MinLevel, params.TOC.MaxLevel)
+		blogtemplate.SetSummary(&te, page, params.ExcerptWords)
 
 		entries = append(entries, te)
+	}
 
-		renderedPage, err := renderBlogPage(tmpl, te)
+	backlinks := links.backlinks()
+
+	site, err := prepareSiteContext(params, entries)
+	if err != nil {
+		return util.Error(err)
+	}
+
+	// Render all articles. This needs every entry to be prepared first for
+	// the navigation between them.
+	for index, file := range params.Files {
+		input := prepareBlogPageInput(params, entries, index)
+
+		for _, from := range backlinks[index] {
+			input.Backlinks = append(input.Backlinks, entries[from])
+		}
+
+		renderedPage, err := renderPage(tmpl.BlogPage, site, input, entries[index].URL)
 
 		if err != nil {
 			return fmt.Errorf("Error encountered while rendering %v: %w", file.Path, err)
 		}
 
-		err = os.WriteFile(
-			filepath.Join(renderPath, finalPath),
-			renderedPage, 0644)
-
+		err = writePage(renderPath, entries[index].URL, renderedPage)
 		if err != nil {
-			return fmt.Errorf("Error encountered while writing %v: %w", filepath.Join(renderPath, finalPath), err)
+			return err
 		}
 	}
 
+	pageInput := RenderPageInput{
+		Title: params.Title,
+		Desc: params.Desc,
+		Tags: params.Tags,
+		Entries: entries,
+	}
+
 	// Prepare blog index
-	indexPage, err := renderIndexPage(tmpl, params, entries)
+	err = writePaginatedPages(renderPath, IndexPageFileName, params.PageSize, tmpl.IndexPage, site, pageInput)
+	if err != nil {
+		return fmt.Errorf("Error encountered while rendering blog index: %w", err)
+	}
+
+	// Prepare front page
+	frontPage, err := renderPage(tmpl.FrontPage, site, pageInput, FrontPageFileName)
 	if err != nil {
 		return util.Error(err)
 	}
 
-	err = os.WriteFile(
-		filepath.Join(renderPath, "blog_index" + ".html"),
-		indexPage, 0644)
+	err = writePage(renderPath, FrontPageFileName, frontPage)
 	if err != nil {
-		return fmt.Errorf("Error encountered while blog index file: %w", err)
+		return err
 	}
 
-	// Prepare front page
-	frontPage, err := renderFrontPage(tmpl, params, entries)
+	// Prepare tag pages
+	err = writeTagPages(renderPath, tmpl, site, params.PageSize, pageInput)
 	if err != nil {
-		return util.Error(err)
+		return err
 	}
 
-	err = os.WriteFile(
-		filepath.Join(renderPath, "index" + ".html"),
-		frontPage, 0644)
+	// Prepare archive pages
+	err = writeArchivePages(renderPath, tmpl, site, pageInput)
 	if err != nil {
-		return fmt.Errorf("Error encountered while site index file: %w", err)
+		return err
+	}
+
+	// Prepare feeds
+	err = writeFeeds(renderPath, params, entries, site.BuildTime)
+	if err != nil {
+		return err
+	}
+
+	// Prepare sitemap
+	err = writeSitemap(renderPath, params, entries)
+	if err != nil {
+		return err
+	}
+
+	// Prepare link graph
+	err = writeGraph(renderPath, params, newGraph(params.Files, pages, links))
+	if err != nil {
+		return err
+	}
+
+	if params.Minify {
+		site.minified.Merge(assetStats)
+		fmt.Fprintf(os.Stderr, "%v\n", site.minified)
 	}
 
 	return nil
@@ -0,0 +1,348 @@
+package render
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aghorui/burlough/blog"
+	"github.com/aghorui/burlough/blogtemplate"
+	"github.com/aghorui/burlough/images"
+	"github.com/aghorui/burlough/parse"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginate(t *testing.T) {
+	{
+		p := paginate(IndexPageFileName, 5, 2)
+
+		assert.Len(t, p, 3, "5 entries should be split into 3 pages of 2")
+		assert.Equal(t, "blog_index.html", p[0].URL, "first page should keep the base path")
+		assert.Equal(t, "blog_index/2.html", p[0].NextURL, "later pages should be in a subdirectory")
+		assert.Empty(t, p[0].PrevURL, "first page should not have a previous page")
+		assert.Empty(t, p[2].NextURL, "last page should not have a next page")
+		assert.True(t, p[1].Pages[1].Current, "current page should be marked")
+	}
+
+	{
+		p := paginate(IndexPageFileName, 5, 0)
+		assert.Len(t, p, 1, "a page size of 0 should not paginate")
+	}
+
+	{
+		p := paginate(IndexPageFileName, 0, 2)
+		assert.Len(t, p, 1, "an empty listing should still have a page")
+	}
+}
+
+func TestRootPrefix(t *testing.T) {
+	assert.Equal(t, "", rootPrefix("index.html"))
+	assert.Equal(t, "../", rootPrefix("tags/a.html"))
+	assert.Equal(t, "../../", rootPrefix("tags/a/2.html"))
+}
+
+func TestBuildArchive(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	entries := []blogtemplate.BlogTemplateEntry{
+		{ Title: "c", CreatedTime: date(2026, time.October, 3) },
+		{ Title: "b", CreatedTime: date(2026, time.October, 1) },
+		{ Title: "a", CreatedTime: date(2025, time.March, 9) },
+	}
+
+	archive := buildArchive(entries)
+
+	assert.Len(t, archive, 2, "entries should be grouped into two years")
+	assert.Equal(t, 2, archive[0].Count, "2026 should have two entries")
+	assert.Equal(t, "archive/2026/index.html", archive[0].URL)
+	assert.Len(t, archive[0].Months, 1, "2026 should have one month")
+	assert.Equal(t, "archive/2026/10/index.html", archive[0].Months[0].URL)
+	assert.Equal(t, "March", archive[1].Months[0].MonthName)
+}
+
+func TestFindRelated(t *testing.T) {
+	entry := func(title string, tags ...string) blogtemplate.BlogTemplateEntry {
+		return blogtemplate.BlogTemplateEntry{ Title: title, TagLinks: blogtemplate.NewTags(tags) }
+	}
+
+	entries := []blogtemplate.BlogTemplateEntry{
+		entry("a", "go", "web"),
+		entry("b", "go"),
+		entry("c", "cooking"),
+		entry("d", "go", "web", "css"),
+	}
+
+	related := findRelated(entries, 0, 5)
+
+	assert.Len(t, related, 2, "only entries sharing tags should be related")
+	assert.Equal(t, "d", related[0].Title, "entries sharing more tags should come first")
+	assert.Equal(t, "b", related[1].Title)
+
+	assert.Len(t, findRelated(entries, 0, 1), 1, "related entries should be limited")
+	assert.Empty(t, findRelated(entries, 0, 0), "a count of 0 should disable related entries")
+
+	input := prepareBlogPageInput(blog.ConfigFileParams{}, entries, 0)
+	assert.Nil(t, input.Next, "the newest entry should not have a next entry")
+	assert.Equal(t, "b", input.Prev.Title, "the previous entry should be the next older one")
+}
+
+func TestCollectTags(t *testing.T) {
+	entry := func(tags ...string) blogtemplate.BlogTemplateEntry {
+		return blogtemplate.BlogTemplateEntry{ TagLinks: blogtemplate.NewTags(tags) }
+	}
+
+	tags, err := collectTags([]blogtemplate.BlogTemplateEntry{ entry("Go", "C++"), entry("go", "C"), entry("C#") })
+	assert.NoError(t, err)
+	assert.Len(t, tags, 4, "tags differing only in case should be merged")
+	assert.Equal(t, "C", tags[0].Name)
+	assert.Equal(t, 2, tags[3].Count)
+
+	_, err = collectTags([]blogtemplate.BlogTemplateEntry{ entry("a b"), entry("a-b") })
+	assert.EqualError(t, err, "tags 'a b' and 'a-b' would both be rendered to tags/a-b.html")
+}
+
+func TestFeedContent(t *testing.T) {
+	e := blogtemplate.BlogTemplateEntry{
+		Content: `<p><a href="other.html#x">a</a> <a href="#fn">b</a> <a href="https://example.org/">c</a>` +
+			`<img src="img/a.png" srcset="img/a-480w.png 480w, img/a.png 900w"></p>`,
+	}
+
+	content, err := feedContent(e, "https://example.com/blog/notes/post.html")
+	assert.NoError(t, err)
+	assert.Contains(t, content, `href="https://example.com/blog/notes/other.html#x"`, "relative links should be made absolute")
+	assert.Contains(t, content, `href="https://example.com/blog/notes/post.html#fn"`)
+	assert.Contains(t, content, `href="https://example.org/"`, "absolute links should be left as they are")
+	assert.Contains(t, content, `src="https://example.com/blog/notes/img/a.png"`)
+	assert.Contains(t, content, `srcset="https://example.com/blog/notes/img/a-480w.png 480w, https://example.com/blog/notes/img/a.png 900w"`)
+}
+
+func TestAtomFeed(t *testing.T) {
+	params := blog.DefaultConfigFileParams()
+	params.BaseURL = "https://example.com/blog"
+	buildTime := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+	feed, err := renderAtomFeed(params, nil, buildTime)
+	assert.NoError(t, err)
+	assert.Contains(t, string(feed), "<updated>2026-10-19T00:00:00Z</updated>", "feeds without entries should use the build time")
+}
+
+func TestAtHostRoot(t *testing.T) {
+	for baseURL, expected := range map[string]bool{
+		"https://example.com": true,
+		"https://example.com/": true,
+		"https://example.com/blog/": false,
+	} {
+		atRoot, err := atHostRoot(baseURL)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, atRoot, baseURL)
+	}
+}
+
+func TestLinkTable(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "untracked.md"), nil, 0644))
+
+	files := []blog.BlogMetadata{ { Path: "a.md" }, { Path: "notes/b.md" }, { Path: "c.md" }, { Path: "notes/c.md" } }
+	links := newLinkTable(dir, files, []string{ "First Post", "Notes", "Same", "Same" }, false)
+
+	p, err := parse.NewParser(blog.DefaultConfigFileParams().Markdown, nil)
+	assert.NoError(t, err)
+
+	render := func(src string, from int, table *linkTable) (string, error) {
+		d, err := p.ParseDocument([]byte(src))
+		assert.NoError(t, err)
+
+		page, err := d.Render(parse.Resolvers{
+			Links: table.fileResolver(from),
+			WikiLinks: table.wikiResolver(from),
+			Resources: table.resourceResolver(from),
+			Attachments: table.attachmentResolver(from),
+		})
+
+		return string(page.Content), err
+	}
+
+	{
+		content, err := render("[b](notes/b.md#part) [a](a.md) [web](https://example.com/c.md)", 0, links)
+		assert.NoError(t, err)
+		assert.Contains(t, content, `href="notes/b.html#part"`, "links should point to the output path")
+		assert.Contains(t, content, `href="a.html"`)
+		assert.Contains(t, content, `href="https://example.com/c.md"`, "external links should be left as they are")
+	}
+
+	{
+		content, err := render("[a](../a.md) [a](/a.md) [[first post]] [[notes/c|the notes]]", 1, links)
+		assert.NoError(t, err)
+		assert.Contains(t, content, `href="../a.html"`, "links should be relative to the linking page")
+		assert.NotContains(t, content, `.md"`)
+		assert.Contains(t, content, `<a class="wikilink" href="../a.html">first post</a>`, "wiki links should match titles")
+		assert.Contains(t, content, `<a class="wikilink" href="c.html">the notes</a>`, "wiki links should match paths")
+	}
+
+	{
+		_, err := render("Text.\n\n[u](untracked.md) [m](missing.md)\n[[Same]] [[Nothing]]", 0, links)
+		assert.EqualError(t, err, "line 3: link to 'untracked.md', which is not tracked\n" +
+			"line 3: link to 'missing.md', which does not exist\n" +
+			"line 4: wiki link to 'Same', which matches more than one file\n" +
+			"line 4: wiki link to 'Nothing', which matches no file")
+	}
+
+	{
+		lenient := newLinkTable(dir, files, nil, true)
+
+		content, err := render("[m](missing.md) [[Nothing]]", 0, lenient)
+		assert.NoError(t, err, "broken links should only be warned about in lenient mode")
+		assert.Contains(t, content, `href="missing.md"`)
+		assert.Contains(t, content, "Nothing</p>", "unresolved wiki links should be left as text")
+	}
+
+	{
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, "notes", "img"), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes", "img", "cat.png"), []byte("cat"), 0644))
+
+		content, err := render("![cat](img/cat.png) [download](/notes/img/cat.png?v=1) [page](other.html)\n\n{{< figure \"img/cat.png\" >}}", 1, links)
+		assert.NoError(t, err)
+		assert.Contains(t, content, `<img src="img/cat.png" alt="cat">`, "images should point to the copied file")
+		assert.Contains(t, content, `href="img/cat.png?v=1"`, "attachments should point to the copied file")
+		assert.Contains(t, content, `href="other.html"`, "links to pages should be left as they are")
+		assert.Contains(t, content, `<img src="img/cat.png"`, "shortcodes should be able to use local files")
+
+		_, err = render("![dog](img/dog.png) ![up](../../outside.png)", 1, links)
+		assert.EqualError(t, err, "line 1: 'img/dog.png' does not exist\nline 1: '../../outside.png' is outside of the blog directory")
+
+		content, err = render("[feed](/rss.xml) [sitemap](/sitemap.xml) [about](/about) [css](/assets/template_main.css) [up](../../outside.pdf)", 1, links)
+		assert.NoError(t, err, "links to files made while rendering should not be an error")
+		assert.Contains(t, content, `href="/rss.xml"`, "links to files that are not in the blog should be left as they are")
+		assert.Contains(t, content, `href="/sitemap.xml"`)
+		assert.Contains(t, content, `href="/about"`)
+		assert.Contains(t, content, `href="/assets/template_main.css"`)
+		assert.Contains(t, content, `href="../../outside.pdf"`)
+
+		content, err = render("![dog](img/dog.png)", 1, newLinkTable(dir, files, nil, true))
+		assert.NoError(t, err, "missing images should only be warned about in lenient mode")
+		assert.Contains(t, content, `src="img/dog.png"`)
+
+		out := t.TempDir()
+		assert.Equal(t, []string{ "notes/img/cat.png" }, links.resourcePaths())
+		assert.NoError(t, copyResources(dir, out, links.resourcePaths(), true))
+		assert.FileExists(t, filepath.Join(out, "notes", "img", "cat.png"), "local files should be copied next to the page")
+	}
+
+	backlinks := links.backlinks()
+	assert.Equal(t, []int{ 1 }, backlinks[0], "links should be counted once per linking file")
+	assert.Equal(t, []int{ 0 }, backlinks[1])
+	assert.Empty(t, backlinks[2])
+}
+
+func TestGraph(t *testing.T) {
+	files := []blog.BlogMetadata{ { Path: "a.md" }, { Path: "b.md" }, { Path: "c.md" } }
+	pages := []blog.BlogFileContents{ { Title: "A" }, { Title: "B \"quoted\"" }, { Title: "C\\ é\a" } }
+
+	links := newLinkTable("", files, nil, false)
+	links.link(0, 1)
+	links.link(0, 1)
+	links.link(1, 1)
+
+	g := newGraph(files, pages, links)
+
+	assert.Equal(t, []GraphEdge{ { Source: "a.md", Target: "b.md" } }, g.Edges, "edges should not be repeated or point to the same post")
+	assert.Equal(t, "b.html", g.Nodes[1].URL)
+
+	orphans := g.Orphans()
+	assert.Len(t, orphans, 2)
+	assert.Equal(t, "a.md", orphans[0].ID)
+	assert.Equal(t, "c.md", orphans[1].ID)
+
+	deadEnds := g.DeadEnds()
+	assert.Len(t, deadEnds, 2)
+	assert.Equal(t, "b.md", deadEnds[0].ID)
+
+	assert.Contains(t, string(g.DOT()), `"b.md" [label="B \"quoted\""];`)
+	assert.Contains(t, string(g.DOT()), `"a.md" -> "b.md";`)
+	assert.Contains(t, string(g.DOT()), "\"c.md\" [label=\"C\\\\ é\a\"];", "only quotes and backslashes should be escaped")
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.md"), []byte("![x](missing.png) [f](missing.pdf) [b](b.md)"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.md"), nil, 0644))
+
+	params := blog.DefaultConfigFileParams()
+	params.Files = files[:2]
+
+	g, err := BuildGraph(dir, &blogtemplate.BlogTemplate{}, params)
+	assert.NoError(t, err, "missing images and attachments should not stop building the graph")
+	assert.Equal(t, []GraphEdge{ { Source: "a.md", Target: "b.md" } }, g.Edges)
+}
+
+func TestBundle(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "trip", "photos"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "trip", "index.md"), nil, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "trip", "photos", "beach.jpg"), nil, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "trip", "route.gpx"), nil, 0644))
+
+	files := []blog.BlogMetadata{ { Path: "trip/index.md", Bundle: true }, { Path: "a.md" } }
+
+	assert.Equal(t, "trip/index.html", OutputPath(files[0]), "bundles should be rendered to <directory>/index.html")
+	assert.Equal(t, "trip", fileName(files[0]))
+
+	resources, err := bundleResources(dir, files[0])
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ "trip/photos/beach.jpg", "trip/route.gpx" }, resources, "every file but index.md should be copied")
+
+	links := newLinkTable(dir, files, nil, false)
+	url, err := links.wikiResolver(1)("trip")
+	assert.NoError(t, err)
+	assert.Equal(t, "trip/index.html", url, "wiki links should match the name of the bundle")
+
+	url, err = links.resourceResolver(0)("photos/beach.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, "photos/beach.jpg", url, "files in the bundle should be linked relative to the page")
+
+	url, err = links.fileResolver(0)("../a.md")
+	assert.NoError(t, err)
+	assert.Equal(t, "../a.html", url)
+}
+
+func TestImages(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "notes", "img"), 0755))
+
+	f, err := os.Create(filepath.Join(dir, "notes", "img", "my shot.png"))
+	assert.NoError(t, err)
+	assert.NoError(t, png.Encode(f, image.NewNRGBA(image.Rect(0, 0, 300, 150))))
+	assert.NoError(t, f.Close())
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes", "img", "broken.jpg"), []byte("broken"), 0644))
+
+	files := []blog.BlogMetadata{ { Path: "notes/b.md" } }
+	links := newLinkTable(dir, files, nil, false)
+	params := blog.ImageParams{ Sizes: "100vw", Lazy: true }
+	resolver := links.imageResolver(0, images.NewProcessor([]int{ 100, 1000 }, t.TempDir()), params)
+
+	attrs, err := resolver("img/my shot.png")
+	assert.NoError(t, err)
+	assert.Equal(t, []parse.ImageAttribute{
+		{ Name: "width", Value: "300" },
+		{ Name: "height", Value: "150" },
+		{ Name: "srcset", Value: "img/my%20shot-100w.png 100w, img/my%20shot.png 300w" },
+		{ Name: "sizes", Value: "100vw" },
+		{ Name: "loading", Value: "lazy" },
+	}, attrs)
+
+	out := t.TempDir()
+	assert.NoError(t, copyVariants(out, links.variants))
+	w, h, err := images.Size(filepath.Join(out, "notes", "img", "my shot-100w.png"))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{ 100, 50 }, []int{ w, h }, "resized copies should be copied next to the image")
+
+	attrs, err = resolver("diagram.svg")
+	assert.NoError(t, err)
+	assert.Empty(t, attrs, "missing images should be left to the resource resolver")
+
+	_, err = resolver("img/broken.jpg")
+	assert.Error(t, err)
+}
@@ -0,0 +1,158 @@
+package render
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aghorui/burlough/blog"
+	"github.com/aghorui/burlough/blogtemplate"
+	"github.com/aghorui/burlough/util"
+)
+
+const SitemapFileName = "sitemap.xml"
+const RobotsFileName  = "robots.txt"
+
+type sitemapURL struct {
+	Loc string                `xml:"loc"`
+	LastMod string            `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name          `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs []sitemapURL         `xml:"url"`
+}
+
+func renderSitemap(
+	params blog.ConfigFileParams,
+	entries []blogtemplate.BlogTemplateEntry) ([]byte, error) {
+	var set sitemapURLSet
+
+	addURL := func(path string, lastMod time.Time) error {
+		loc, err := util.JoinURL(params.BaseURL, path)
+		if err != nil {
+			return util.Error(err)
+		}
+
+		u := sitemapURL{ Loc: loc }
+
+		if !lastMod.IsZero() {
+			u.LastMod = lastMod.Format(time.RFC3339)
+		}
+
+		set.URLs = append(set.URLs, u)
+		return nil
+	}
+
+	latest := latestModified(entries)
+
+	// The front page is served as the base URL itself.
+	if err := addURL("", latest); err != nil {
+		return nil, err
+	}
+
+	if err := addURL(IndexPageFileName, latest); err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if e.NoIndex {
+			continue
+		}
+
+		if err := addURL(e.URL, entryLastModified(e)); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := xml.MarshalIndent(set, "", "\t")
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+// Whether the base URL is the root of its host. Crawlers only read robots.txt
+// from the root, so it is not generated for blogs hosted under a path.
+func atHostRoot(baseURL string) (bool, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return false, util.Error(err)
+	}
+
+	return strings.Trim(u.Path, "/") == "", nil
+}
+
+func renderRobots(params blog.ConfigFileParams) ([]byte, error) {
+	sitemapURL, err := util.JoinURL(params.BaseURL, SitemapFileName)
+	if err != nil {
+		return nil, util.Error(err)
+	}
+
+	var b strings.Builder
+
+	b.WriteString("User-agent: *\n")
+	b.WriteString("Allow: /\n")
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "Sitemap: %v\n", sitemapURL)
+
+	return []byte(b.String()), nil
+}
+
+// Generates sitemap.xml and robots.txt into renderPath.
+func writeSitemap(
+	renderPath string,
+	params blog.ConfigFileParams,
+	entries []blogtemplate.BlogTemplateEntry) error {
+	if !params.Sitemap {
+		return nil
+	}
+
+	if params.BaseURL == "" {
+		fmt.Fprintf(os.Stderr, "Warning: skipping sitemap generation: %v\n", ErrNoBaseURL)
+		return nil
+	}
+
+	sitemap, err := renderSitemap(params, entries)
+	if err != nil {
+		return util.Error(err)
+	}
+
+	err = os.WriteFile(filepath.Join(renderPath, SitemapFileName), sitemap, 0644)
+	if err != nil {
+		return fmt.Errorf("Error encountered while writing sitemap: %w", err)
+	}
+
+	atRoot, err := atHostRoot(params.BaseURL)
+	if err != nil {
+		return err
+	}
+
+	if !atRoot {
+		sitemapLink, err := util.JoinURL(params.BaseURL, SitemapFileName)
+		if err != nil {
+			return util.Error(err)
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: skipping robots.txt generation: crawlers only read it from the root of %v. " +
+			"Add 'Sitemap: %v' to the robots.txt at the root instead.\n", params.BaseURL, sitemapLink)
+		return nil
+	}
+
+	robots, err := renderRobots(params)
+	if err != nil {
+		return util.Error(err)
+	}
+
+	err = os.WriteFile(filepath.Join(renderPath, RobotsFileName), robots, 0644)
+	if err != nil {
+		return fmt.Errorf("Error encountered while writing robots.txt: %w", err)
+	}
+
+	return nil
+}
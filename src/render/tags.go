@@ -0,0 +1,89 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aghorui/burlough/blogtemplate"
+)
+
+// Whether two tag names are the same tag, ignoring case and whitespace.
+func sameTag(a string, b string) bool {
+	return strings.EqualFold(strings.Join(strings.Fields(a), " "), strings.Join(strings.Fields(b), " "))
+}
+
+// Collects every tag used by the given entries along with the number of
+// entries using them. The result is sorted by name. Different tags with the
+// same slug are an error, as they would share a tag page.
+func collectTags(entries []blogtemplate.BlogTemplateEntry) ([]blogtemplate.Tag, error) {
+	tags := make([]blogtemplate.Tag, 0)
+	index := make(map[string]int)
+
+	for _, e := range entries {
+		for _, t := range e.TagLinks {
+			if i, ok := index[t.Slug]; ok {
+				if !sameTag(tags[i].Name, t.Name) {
+					return nil, fmt.Errorf("tags '%v' and '%v' would both be rendered to %v", tags[i].Name, t.Name, t.URL)
+				}
+
+				tags[i].Count++
+				continue
+			}
+
+			t.Count = 1
+			tags = append(tags, t)
+			index[t.Slug] = len(tags) - 1
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags, nil
+}
+
+// Returns the entries which have the given tag.
+func entriesWithTag(entries []blogtemplate.BlogTemplateEntry, tag blogtemplate.Tag) []blogtemplate.BlogTemplateEntry {
+	ret := make([]blogtemplate.BlogTemplateEntry, 0)
+
+	for _, e := range entries {
+		for _, t := range e.TagLinks {
+			if t.Slug == tag.Slug {
+				ret = append(ret, e)
+				break
+			}
+		}
+	}
+
+	return ret
+}
+
+// Renders a page for every tag and the tag overview page.
+func writeTagPages(
+	renderPath string,
+	tmpl *blogtemplate.BlogTemplate,
+	site *SiteContext,
+	pageSize int,
+	pageInput RenderPageInput) error {
+	for i := range site.Tags {
+		tag := site.Tags[i]
+
+		input := pageInput
+		input.Tag = &tag
+		input.Entries = entriesWithTag(pageInput.Entries, tag)
+
+		err := writePaginatedPages(renderPath, tag.URL, pageSize, tmpl.TagPage, site, input)
+		if err != nil {
+			return fmt.Errorf("Error encountered while rendering tag page for '%v': %w", tag.Name, err)
+		}
+	}
+
+	page, err := renderPage(tmpl.TagPage, site, pageInput, site.Pages.Tags)
+	if err != nil {
+		return fmt.Errorf("Error encountered while rendering tag overview page: %w", err)
+	}
+
+	return writePage(renderPath, site.Pages.Tags, page)
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 
 	"github.com/chzyer/readline"
@@ -13,7 +14,10 @@ import (
 	"github.com/aghorui/burlough/blog"
 	"github.com/aghorui/burlough/blogtemplate"
 	"github.com/aghorui/burlough/constants"
+	"github.com/aghorui/burlough/linkcheck"
+	"github.com/aghorui/burlough/parse"
 	"github.com/aghorui/burlough/project"
+	"github.com/aghorui/burlough/render"
 	"github.com/aghorui/burlough/util"
 )
 
@@ -27,6 +31,9 @@ const (
 	CommandList       = "list"
 	CommandEdit       = "edit"
 	CommandRender     = "render"
+	CommandHighlight  = "highlight"
+	CommandGraph      = "graph"
+	CommandLinkCheck  = "linkcheck"
 )
 
 const usageString =
@@ -41,6 +48,9 @@ The subcommands are:
 	list      List all tracked files in project
 	edit      Edit a given file
 	render    Render the project into a finished blog
+	highlight List highlight styles or print their stylesheets
+	graph     Print the links between posts, or the posts lacking them
+	linkcheck Check the links in the rendered blog
 
 The following arguments are also supported:
 
@@ -57,6 +67,28 @@ The subcommands are:
 
 `
 
+const highlightUsageString =
+`Usage: %v highlight <subcommand> [arguments]
+
+The subcommands are:
+
+	styles                Lists the available highlight styles
+	css [style]           Prints the stylesheet of a highlight style
+
+`
+
+const graphUsageString =
+`Usage: %v graph <subcommand>
+
+The subcommands are:
+
+	dot                   Prints the link graph in the Graphviz DOT format
+	json                  Prints the link graph as JSON
+	orphans               Lists the posts no other post links to
+	deadends              Lists the posts linking to no other post
+
+`
+
 var ErrInvalidArguments        = fmt.Errorf("Invalid Arguments.")
 var ErrProjectAlreadyExists    = fmt.Errorf("Project file already exists in current folder.")
 var ErrProjectDoesNotExist     = fmt.Errorf("Project file does not exist in current folder. Create a project using the 'init' subcommand.")
@@ -64,6 +96,7 @@ var ErrInvalidMetadataType     = fmt.Errorf("Invalid header metadata type. Type
 var ErrMalformedConfigFile     = fmt.Errorf("Config file values seem to be incorrect. Have you modified them?")
 var ErrNoBlogFiles             = fmt.Errorf("There are no tracked blog files in the current directory. Please add the files in the directory using the 'scan' subcommand.")
 var ErrNoConfigOptionSpecified = fmt.Errorf("No Config Option Specified.")
+var ErrInvalidImageWidths      = fmt.Errorf("Invalid image widths. Widths must be a comma separated list of positive numbers.")
 
 func LoadConfig(args []string) error {
 	var showVersion bool
@@ -82,7 +115,8 @@ func LoadConfig(args []string) error {
 
 	switch args[1] {
 	case CommandInit:
-		var c blog.ConfigFileParams
+		c := blog.DefaultConfigFileParams()
+		c.Images.Widths = blog.DefaultImageWidths
 		var tags string
 		var scan bool
 		var wizard bool
@@ -92,6 +126,7 @@ func LoadConfig(args []string) error {
 		initFlags.StringVar(&c.Title, "title", "My Blog", "Name of your blog.")
 		initFlags.StringVar(&c.Desc, "description", "", "Short description of your blog.")
 		initFlags.StringVar(&tags, "tags", "", "Global Tags for blog.")
+		initFlags.StringVar(&c.BaseURL, "base_url", "", "Absolute URL the blog will be hosted at.")
 		initFlags.StringVar(&c.RenderPath, "renderpath", "../blogfiles", "Output directory for your blog.")
 		initFlags.StringVar(&c.TemplatePath, "templatepath", "", "Template for your blog.")
 		initFlags.StringVar(&metadataType, "metadata_type", "toml", "Default Header Metadata Type for your files (toml/yaml).")
@@ -203,6 +238,93 @@ func LoadConfig(args []string) error {
 
 			case "use_file_timestamp_as_creation_date":
 				fmt.Printf("%v\n", state.UseFileTimestampAsCreationDate)
+
+			case "base_url":
+				fmt.Printf("%v\n", state.BaseURL)
+
+			case "feed_rss":
+				fmt.Printf("%v\n", state.Feed.RSS)
+
+			case "feed_atom":
+				fmt.Printf("%v\n", state.Feed.Atom)
+
+			case "feed_json":
+				fmt.Printf("%v\n", state.Feed.JSON)
+
+			case "feed_max_items":
+				fmt.Printf("%v\n", state.Feed.MaxItems)
+
+			case "feed_full_content":
+				fmt.Printf("%v\n", state.Feed.FullContent)
+
+			case "sitemap":
+				fmt.Printf("%v\n", state.Sitemap)
+
+			case "page_size":
+				fmt.Printf("%v\n", state.PageSize)
+
+			case "related_posts":
+				fmt.Printf("%v\n", state.RelatedPosts)
+
+			case "toc_min_level":
+				fmt.Printf("%v\n", state.TOC.MinLevel)
+
+			case "toc_max_level":
+				fmt.Printf("%v\n", state.TOC.MaxLevel)
+
+			case "excerpt_words":
+				fmt.Printf("%v\n", state.ExcerptWords)
+
+			case "markdown_highlight_style":
+				fmt.Printf("%v\n", state.Markdown.HighlightStyle)
+
+			case "markdown_highlight_dark_style":
+				fmt.Printf("%v\n", state.Markdown.HighlightDarkStyle)
+
+			case "markdown_highlight_classes":
+				fmt.Printf("%v\n", state.Markdown.HighlightClasses)
+
+			case "markdown_line_numbers":
+				fmt.Printf("%v\n", state.Markdown.LineNumbers)
+
+			case "markdown_unsafe":
+				fmt.Printf("%v\n", state.Markdown.Unsafe)
+
+			case "markdown_footnotes":
+				fmt.Printf("%v\n", state.Markdown.Footnotes)
+
+			case "markdown_definition_lists":
+				fmt.Printf("%v\n", state.Markdown.DefinitionLists)
+
+			case "markdown_typographer":
+				fmt.Printf("%v\n", state.Markdown.Typographer)
+
+			case "markdown_emoji":
+				fmt.Printf("%v\n", state.Markdown.Emoji)
+
+			case "markdown_math":
+				fmt.Printf("%v\n", state.Markdown.Math)
+
+			case "lenient_links":
+				fmt.Printf("%v\n", state.LenientLinks)
+
+			case "graph":
+				fmt.Printf("%v\n", state.Graph)
+
+			case "images_widths":
+				fmt.Printf("%v\n", formatWidths(state.Images.Widths))
+
+			case "images_sizes":
+				fmt.Printf("%v\n", state.Images.Sizes)
+
+			case "images_lazy":
+				fmt.Printf("%v\n", state.Images.Lazy)
+
+			case "images_strip_metadata":
+				fmt.Printf("%v\n", state.Images.StripMetadata)
+
+			case "minify":
+				fmt.Printf("%v\n", state.Minify)
 			}
 
 
@@ -220,6 +342,7 @@ func LoadConfig(args []string) error {
 
 			var tags string
 			var metadataType string
+			var imageWidths string = formatWidths(state.Images.Widths)
 
 			switch state.MetadataType {
 			case blog.TOML:
@@ -238,6 +361,35 @@ func LoadConfig(args []string) error {
 			cfgFlags.StringVar(&state.TemplatePath, "templatepath", state.TemplatePath, "Template for your blog.")
 			cfgFlags.StringVar(&metadataType, "metadata_type", metadataType, "Default Header Metadata Type for your files (toml/yaml).")
 			cfgFlags.BoolVar(&state.UseFileTimestampAsCreationDate,  "use_file_timestamp_as_creation_date", state.UseFileTimestampAsCreationDate, "Use the file modification time as the creation date.")
+			cfgFlags.StringVar(&state.BaseURL, "base_url", state.BaseURL, "Absolute URL the blog will be hosted at.")
+			cfgFlags.BoolVar(&state.Feed.RSS, "feed_rss", state.Feed.RSS, "Generate an RSS feed.")
+			cfgFlags.BoolVar(&state.Feed.Atom, "feed_atom", state.Feed.Atom, "Generate an Atom feed.")
+			cfgFlags.BoolVar(&state.Feed.JSON, "feed_json", state.Feed.JSON, "Generate a JSON Feed.")
+			cfgFlags.IntVar(&state.Feed.MaxItems, "feed_max_items", state.Feed.MaxItems, "Maximum number of entries in feeds (0 for all).")
+			cfgFlags.BoolVar(&state.Feed.FullContent, "feed_full_content", state.Feed.FullContent, "Include the full content of entries in feeds.")
+			cfgFlags.BoolVar(&state.Sitemap, "sitemap", state.Sitemap, "Generate sitemap.xml and robots.txt.")
+			cfgFlags.IntVar(&state.PageSize, "page_size", state.PageSize, "Number of entries per listing page (0 for a single page).")
+			cfgFlags.IntVar(&state.RelatedPosts, "related_posts", state.RelatedPosts, "Maximum number of related posts shown on a post.")
+			cfgFlags.IntVar(&state.TOC.MinLevel, "toc_min_level", state.TOC.MinLevel, "Smallest heading level included in the table of contents.")
+			cfgFlags.IntVar(&state.TOC.MaxLevel, "toc_max_level", state.TOC.MaxLevel, "Largest heading level included in the table of contents.")
+			cfgFlags.IntVar(&state.ExcerptWords, "excerpt_words", state.ExcerptWords, "Number of words in generated excerpts.")
+			cfgFlags.StringVar(&state.Markdown.HighlightStyle, "markdown_highlight_style", state.Markdown.HighlightStyle, "Chroma style used for highlighting code blocks.")
+			cfgFlags.StringVar(&state.Markdown.HighlightDarkStyle, "markdown_highlight_dark_style", state.Markdown.HighlightDarkStyle, "Chroma style used for code blocks with a dark color scheme. Needs markdown_highlight_classes.")
+			cfgFlags.BoolVar(&state.Markdown.HighlightClasses, "markdown_highlight_classes", state.Markdown.HighlightClasses, "Highlight code blocks with CSS classes from assets/syntax.css instead of inline styles.")
+			cfgFlags.BoolVar(&state.Markdown.LineNumbers, "markdown_line_numbers", state.Markdown.LineNumbers, "Show line numbers in code blocks.")
+			cfgFlags.BoolVar(&state.Markdown.Unsafe, "markdown_unsafe", state.Markdown.Unsafe, "Keep raw HTML in markdown files.")
+			cfgFlags.BoolVar(&state.Markdown.Footnotes, "markdown_footnotes", state.Markdown.Footnotes, "Enable footnotes.")
+			cfgFlags.BoolVar(&state.Markdown.DefinitionLists, "markdown_definition_lists", state.Markdown.DefinitionLists, "Enable definition lists.")
+			cfgFlags.BoolVar(&state.Markdown.Typographer, "markdown_typographer", state.Markdown.Typographer, "Replace quotes, dashes and ellipses with typographic ones.")
+			cfgFlags.BoolVar(&state.Markdown.Emoji, "markdown_emoji", state.Markdown.Emoji, "Replace emoji shortcodes such as :smile:.")
+			cfgFlags.BoolVar(&state.Markdown.Math, "markdown_math", state.Markdown.Math, "Render $...$ and $$...$$ TeX math as MathML.")
+			cfgFlags.BoolVar(&state.LenientLinks, "lenient_links", state.LenientLinks, "Warn about links to missing or untracked files instead of failing.")
+			cfgFlags.BoolVar(&state.Graph, "graph", state.Graph, "Generate graph.json with the links between posts.")
+			cfgFlags.StringVar(&imageWidths, "images_widths", imageWidths, "Comma separated widths of the resized copies made of PNG and JPEG images (empty to disable).")
+			cfgFlags.StringVar(&state.Images.Sizes, "images_sizes", state.Images.Sizes, "sizes attribute of images with resized copies.")
+			cfgFlags.BoolVar(&state.Images.Lazy, "images_lazy", state.Images.Lazy, "Load images lazily with loading=\"lazy\".")
+			cfgFlags.BoolVar(&state.Images.StripMetadata, "images_strip_metadata", state.Images.StripMetadata, "Remove EXIF and XMP metadata, including locations, from copied JPEG and PNG images.")
+			cfgFlags.BoolVar(&state.Minify, "minify", state.Minify, "Minify rendered pages and template CSS/JS assets.")
 
 			_ = cfgFlags.Parse(args[3:])
 
@@ -257,6 +409,11 @@ func LoadConfig(args []string) error {
 
 			state.Tags = util.SplitCommaList(tags)
 
+			state.Images.Widths, err = parseWidths(imageWidths)
+			if err != nil {
+				return err
+			}
+
 			err = state.WriteConfig()
 			if err != nil {
 				return err
@@ -290,6 +447,35 @@ func LoadConfig(args []string) error {
 			}
 
 			fmt.Printf("use_file_timestamp_as_creation_date='%v'\n", state.UseFileTimestampAsCreationDate)
+			fmt.Printf("base_url='%v'\n", state.BaseURL)
+			fmt.Printf("feed_rss='%v'\n", state.Feed.RSS)
+			fmt.Printf("feed_atom='%v'\n", state.Feed.Atom)
+			fmt.Printf("feed_json='%v'\n", state.Feed.JSON)
+			fmt.Printf("feed_max_items='%v'\n", state.Feed.MaxItems)
+			fmt.Printf("feed_full_content='%v'\n", state.Feed.FullContent)
+			fmt.Printf("sitemap='%v'\n", state.Sitemap)
+			fmt.Printf("page_size='%v'\n", state.PageSize)
+			fmt.Printf("related_posts='%v'\n", state.RelatedPosts)
+			fmt.Printf("toc_min_level='%v'\n", state.TOC.MinLevel)
+			fmt.Printf("toc_max_level='%v'\n", state.TOC.MaxLevel)
+			fmt.Printf("excerpt_words='%v'\n", state.ExcerptWords)
+			fmt.Printf("markdown_highlight_style='%v'\n", state.Markdown.HighlightStyle)
+			fmt.Printf("markdown_highlight_dark_style='%v'\n", state.Markdown.HighlightDarkStyle)
+			fmt.Printf("markdown_highlight_classes='%v'\n", state.Markdown.HighlightClasses)
+			fmt.Printf("markdown_line_numbers='%v'\n", state.Markdown.LineNumbers)
+			fmt.Printf("markdown_unsafe='%v'\n", state.Markdown.Unsafe)
+			fmt.Printf("markdown_footnotes='%v'\n", state.Markdown.Footnotes)
+			fmt.Printf("markdown_definition_lists='%v'\n", state.Markdown.DefinitionLists)
+			fmt.Printf("markdown_typographer='%v'\n", state.Markdown.Typographer)
+			fmt.Printf("markdown_emoji='%v'\n", state.Markdown.Emoji)
+			fmt.Printf("markdown_math='%v'\n", state.Markdown.Math)
+			fmt.Printf("lenient_links='%v'\n", state.LenientLinks)
+			fmt.Printf("graph='%v'\n", state.Graph)
+			fmt.Printf("images_widths='%v'\n", formatWidths(state.Images.Widths))
+			fmt.Printf("images_sizes='%v'\n", state.Images.Sizes)
+			fmt.Printf("images_lazy='%v'\n", state.Images.Lazy)
+			fmt.Printf("images_strip_metadata='%v'\n", state.Images.StripMetadata)
+			fmt.Printf("minify='%v'\n", state.Minify)
 
 
 		default:
@@ -332,6 +518,66 @@ func LoadConfig(args []string) error {
 			return err
 		}
 
+	case CommandHighlight:
+		if len(args) - 1 < 2 {
+			fmt.Fprintf(os.Stderr, highlightUsageString, args[0])
+			return ErrInvalidArguments
+		}
+
+		switch args[2] {
+		case "styles":
+			for _, name := range parse.HighlightStyles() {
+				fmt.Println(name)
+			}
+
+		case "css":
+			if len(args) - 1 < 3 {
+				fmt.Fprintf(os.Stderr, highlightUsageString, args[0])
+				return ErrInvalidArguments
+			}
+
+			css, err := parse.StyleCSS(args[3], blog.DefaultConfigFileParams().Markdown)
+			if err != nil {
+				return err
+			}
+
+			fmt.Print(string(css))
+
+		default:
+			fmt.Fprintf(os.Stderr, highlightUsageString, args[0])
+			return ErrInvalidArguments
+		}
+
+	case CommandGraph:
+		if len(args) - 1 < 2 {
+			fmt.Fprintf(os.Stderr, graphUsageString, args[0])
+			return ErrInvalidArguments
+		}
+
+		switch args[2] {
+		case "dot", "json", "orphans", "deadends":
+			err := printGraph(args[2])
+			if err != nil {
+				return err
+			}
+
+		default:
+			fmt.Fprintf(os.Stderr, graphUsageString, args[0])
+			return ErrInvalidArguments
+		}
+
+	case CommandLinkCheck:
+		var renderOverride string
+		checkFlags := flag.NewFlagSet("linkcheck", flag.ExitOnError)
+		checkFlags.StringVar(&renderOverride, "path", "", "Output directory of your blog. (override)")
+
+		_ = checkFlags.Parse(args[2:])
+
+		err := checkLinks(renderOverride)
+		if err != nil {
+			return err
+		}
+
 	default:
 		_ = defaultFlags.Parse(args[1:])
 
@@ -370,6 +616,33 @@ func isFlagSet(fset *flag.FlagSet) bool {
 	return flagIsSet
 }
 
+// Returns image widths as a comma separated list.
+func formatWidths(widths []int) string {
+	list := make([]string, 0, len(widths))
+
+	for _, w := range widths {
+		list = append(list, strconv.Itoa(w))
+	}
+
+	return strings.Join(list, ",")
+}
+
+// Parses a comma separated list of image widths.
+func parseWidths(s string) ([]int, error) {
+	widths := make([]int, 0)
+
+	for _, item := range util.SplitCommaList(s) {
+		w, err := strconv.Atoi(item)
+		if err != nil || w <= 0 {
+			return nil, ErrInvalidImageWidths
+		}
+
+		widths = append(widths, w)
+	}
+
+	return widths, nil
+}
+
 func projectFileExists() bool {
 	_, err := os.Stat(project.ProjectConfigFileName)
 
@@ -475,6 +748,10 @@ func initWizard(defaults blog.ConfigFileParams) (blog.ConfigFileParams, error) {
 		params.Tags = util.SplitCommaList(tagStr)
 	}
 
+	if err := wizardPromptString(rl, "URL the blog will be hosted at (needed for feeds)", params.BaseURL, &params.BaseURL); err != nil {
+		return params, err
+	}
+
 	if err := wizardPromptString(rl, "Output path for blog", params.RenderPath, &params.RenderPath); err != nil {
 		return params, err
 	}
@@ -750,6 +1027,108 @@ func editFile(filename string) error {
 	return nil
 }
 
+// Prints the link graph of the project in a format, or the orphan or dead-end
+// posts in it.
+func printGraph(format string) error {
+	if !projectFileExists() {
+		return ErrProjectDoesNotExist
+	}
+
+	path, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	state, err := project.Load(path)
+	if err != nil {
+		return err
+	}
+
+	g, err := state.LinkGraph()
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "dot":
+		fmt.Print(string(g.DOT()))
+
+	case "json":
+		data, err := g.JSON()
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(string(data))
+
+	case "orphans":
+		for _, n := range g.Orphans() {
+			fmt.Printf("%v (%v)\n", n.ID, n.Title)
+		}
+
+	case "deadends":
+		for _, n := range g.DeadEnds() {
+			fmt.Printf("%v (%v)\n", n.ID, n.Title)
+		}
+	}
+
+	return nil
+}
+
+// Checks the links in the rendered blog and prints the broken ones.
+func checkLinks(renderOverride string) error {
+	if !projectFileExists() {
+		return ErrProjectDoesNotExist
+	}
+
+	path, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	state, err := project.Load(path)
+	if err != nil {
+		return err
+	}
+
+	renderPath := state.RenderPath
+
+	if renderOverride != "" {
+		renderPath = renderOverride
+	}
+
+	links, err := state.PageLinks()
+	if err != nil {
+		return err
+	}
+
+	sources := make(map[string]linkcheck.Source, len(state.Files))
+
+	for _, file := range state.Files {
+		page := render.OutputPath(file)
+		sources[page] = linkcheck.Source{ Path: file.Path, Links: links[page] }
+	}
+
+	fmt.Fprintf(os.Stderr, "Checking links in %v\n", renderPath)
+
+	problems, err := linkcheck.Check(renderPath, sources)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Found %v broken links.", len(problems))
+	}
+
+	fmt.Fprintf(os.Stderr, "No broken links found.\n")
+
+	return nil
+}
+
 func renderProject(renderOverride string) error {
 	if !projectFileExists() {
 		return ErrProjectDoesNotExist
@@ -27,6 +27,10 @@ This is another paragraph.
 //go:embed default_export_template/*
 var DefaultExportTemplate embed.FS
 
+// Templates of the built-in shortcodes, named after the shortcode.
+//go:embed shortcodes/*
+var BuiltinShortcodes embed.FS
+
 //go:embed file_template/none.md
 var BlogTemplateNoneData []byte
 
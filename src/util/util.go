@@ -1,6 +1,7 @@
 package util
 
 import (
+	"net/url"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -21,6 +22,13 @@ var SanitizeRegex *regexp.Regexp = func() *regexp.Regexp {
 	return re
 }()
 
+// Creates a lowercase URL/filename-safe slug out of a string. Whitespace is
+// replaced with '-'.
+func Slugify(s string) string {
+	sl := strings.Join(strings.Fields(s), "-")
+	return strings.ToLower(SanitizeRegex.ReplaceAllString(sl, ""))
+}
+
 // Convenience function for stripping the file extension from a file
 func ExtractFilename(s string) string {
 	return strings.TrimSuffix(s, filepath.Ext(s))
@@ -49,4 +57,33 @@ func SplitCommaList(s string) []string {
 // Standard timestamp.
 func GetStandardTimestampString(t time.Time) string {
 	return t.Format("02 January 2006")
-}
\ No newline at end of file
+}
+
+// Joins a base URL and a site-relative path into an absolute URL. The path of
+// the base URL is kept, e.g. ("https://a.com/blog", "b.html") becomes
+// "https://a.com/blog/b.html".
+func JoinURL(base string, path string) (string, error) {
+	b, err := url.Parse(strings.TrimSuffix(base, "/") + "/")
+	if err != nil {
+		return "", err
+	}
+
+	p, err := url.Parse(strings.TrimPrefix(filepath.ToSlash(path), "/"))
+	if err != nil {
+		return "", err
+	}
+
+	return b.ResolveReference(p).String(), nil
+}
+
+// Shortens a text to its first n words, appending an ellipsis if anything was
+// cut off. Returns whether the text was shortened.
+func TruncateWords(s string, n int) (string, bool) {
+	words := strings.Fields(s)
+
+	if n <= 0 || len(words) <= n {
+		return strings.Join(words, " "), false
+	}
+
+	return strings.Join(words[:n], " ") + "…", true
+}
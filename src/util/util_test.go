@@ -1 +1,31 @@
-package util
\ No newline at end of file
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoinURL(t *testing.T) {
+	{
+		u, err := JoinURL("https://example.com/blog", "a.html")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/blog/a.html", u, "base path should be kept")
+	}
+
+	{
+		u, err := JoinURL("https://example.com/", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/", u, "should return the base URL")
+	}
+}
+
+func TestTruncateWords(t *testing.T) {
+	s, truncated := TruncateWords("one  two\nthree four", 3)
+	assert.Equal(t, "one two three…", s)
+	assert.True(t, truncated)
+
+	s, truncated = TruncateWords("one two", 3)
+	assert.Equal(t, "one two", s)
+	assert.False(t, truncated)
+}